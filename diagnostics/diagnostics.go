@@ -0,0 +1,231 @@
+// Package diagnostics renders parser and runtime errors against the source
+// they came from, underlining the offending span and any related locations.
+package diagnostics
+
+import (
+	"fmt"
+	"os"
+	"pastel/token"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Label marks a span of source, optionally with a short message such as
+// "variable declared here".
+type Label struct {
+	Pos token.Position
+	End token.Position
+	Msg string
+}
+
+// Diagnostic is a single error report. Primary marks the span the error is
+// about; Secondary labels point at related locations.
+type Diagnostic struct {
+	Kind      string // e.g. "Parser Error" or "Pascal Error"
+	Msg       string
+	Detail    string
+	Hint      string
+	Primary   Label
+	Secondary []Label
+}
+
+// Reporter is implemented by errors that can describe themselves as a
+// Diagnostic.
+type Reporter interface {
+	Diagnostic() *Diagnostic
+}
+
+// ANSI escape sequences used when color is enabled.
+const (
+	reset   = "\x1b[0m"
+	bold    = "\x1b[1m"
+	red     = "\x1b[31m"
+	blue    = "\x1b[34m"
+	cyan    = "\x1b[36m"
+	boldRed = "\x1b[1;31m"
+)
+
+// Renderer formats diagnostics, quoting lines from the source when it is
+// available.
+type Renderer struct {
+	lines []string
+	color bool
+}
+
+// NewRenderer creates a renderer for the given source text. An empty source
+// renders diagnostics without snippets.
+func NewRenderer(source string, color bool) *Renderer {
+	r := &Renderer{color: color}
+	if source != "" {
+		r.lines = strings.Split(source, "\n")
+		for i, line := range r.lines {
+			r.lines[i] = strings.TrimSuffix(line, "\r")
+		}
+	}
+	return r
+}
+
+// UseColor reports whether output written to f should be colored: f must be
+// a terminal and the NO_COLOR environment variable must not be set.
+func UseColor(f *os.File) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Format renders d without source snippets or color.
+func Format(d *Diagnostic) string {
+	return NewRenderer("", false).Render(d)
+}
+
+// Render formats d as a header line, the labelled source lines, and the
+// detail and hint.
+func (r *Renderer) Render(d *Diagnostic) string {
+	var b strings.Builder
+
+	b.WriteString(r.paint(boldRed, "["+d.Kind+"]"))
+	b.WriteString(" ")
+	if d.Primary.Pos.IsValid() || d.Primary.Pos.Filename != "" {
+		b.WriteString(r.paint(bold, d.Primary.Pos.String()+":"))
+		b.WriteString(" ")
+	}
+	b.WriteString(r.paint(bold, d.Msg))
+
+	r.writeSnippet(&b, d)
+
+	if d.Detail != "" {
+		fmt.Fprintf(&b, "\n  → %s", d.Detail)
+	}
+	if d.Hint != "" {
+		fmt.Fprintf(&b, "\n  %s %s", r.paint(cyan, "💡 Hint:"), d.Hint)
+	}
+	return b.String()
+}
+
+// marker is a label resolved to a single source line.
+type marker struct {
+	line    int
+	col     int // 1-based byte column where the underline starts
+	endCol  int // byte column just past the underline
+	msg     string
+	primary bool
+}
+
+func (r *Renderer) writeSnippet(b *strings.Builder, d *Diagnostic) {
+	if r.lines == nil {
+		return
+	}
+
+	var markers []marker
+	if m, ok := r.resolve(d.Primary, true); ok {
+		markers = append(markers, m)
+	}
+	for _, l := range d.Secondary {
+		if m, ok := r.resolve(l, false); ok {
+			markers = append(markers, m)
+		}
+	}
+	if len(markers) == 0 {
+		return
+	}
+
+	// Collect the lines to show, in source order.
+	var shown []int
+	maxLine := 0
+	for _, m := range markers {
+		if !slices.Contains(shown, m.line) {
+			shown = append(shown, m.line)
+		}
+		maxLine = max(maxLine, m.line)
+	}
+	slices.Sort(shown)
+
+	width := len(fmt.Sprint(maxLine))
+	gutter := strings.Repeat(" ", width+1) + r.paint(blue, "|")
+
+	b.WriteString("\n" + gutter)
+	for i, line := range shown {
+		if i > 0 && line != shown[i-1]+1 {
+			b.WriteString("\n" + r.paint(blue, strings.Repeat(".", width+1)))
+		}
+		text := r.lines[line-1]
+		fmt.Fprintf(b, "\n%s %s %s", r.paint(blue, fmt.Sprintf("%*d", width, line)), r.paint(blue, "|"), text)
+
+		// Primary markers first, then secondary ones left to right.
+		for _, primary := range []bool{true, false} {
+			for _, m := range markers {
+				if m.line != line || m.primary != primary {
+					continue
+				}
+				b.WriteString("\n" + gutter + " ")
+				b.WriteString(padding(text, m.col))
+				glyph, color := "-", blue
+				if m.primary {
+					glyph, color = "^", red
+				}
+				underline := strings.Repeat(glyph, spanWidth(text, m.col, m.endCol))
+				if m.msg != "" {
+					underline += " " + m.msg
+				}
+				b.WriteString(r.paint(color, underline))
+			}
+		}
+	}
+}
+
+// resolve clamps l to a single line of the source. Spans that cover several
+// lines are underlined to the end of their first line.
+func (r *Renderer) resolve(l Label, primary bool) (marker, bool) {
+	if !l.Pos.IsValid() || l.Pos.Line > len(r.lines) {
+		return marker{}, false
+	}
+	text := r.lines[l.Pos.Line-1]
+	col := min(l.Pos.Column, len(text)+1)
+	endCol := col + 1
+	if l.End.IsValid() && l.End.Line == l.Pos.Line && l.End.Column > col {
+		endCol = l.End.Column
+	} else if l.End.IsValid() && l.End.Line > l.Pos.Line {
+		endCol = len(text) + 1
+	}
+	return marker{line: l.Pos.Line, col: col, endCol: max(endCol, col+1), msg: l.Msg, primary: primary}, true
+}
+
+// padding returns whitespace that lines up with column col of text, keeping
+// tabs so the underline stays aligned however the terminal expands them.
+func padding(text string, col int) string {
+	var b strings.Builder
+	prefix := text[:min(col-1, len(text))]
+	for _, ch := range prefix {
+		if ch == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
+
+// spanWidth returns the number of characters between byte columns col and
+// endCol of text, and at least one.
+func spanWidth(text string, col, endCol int) int {
+	from := min(col-1, len(text))
+	to := min(endCol-1, len(text))
+	n := utf8.RuneCountInString(text[from:to])
+	if endCol-1 > len(text) {
+		n += endCol - 1 - max(len(text), col-1)
+	}
+	return max(n, 1)
+}
+
+func (r *Renderer) paint(color, s string) string {
+	if !r.color {
+		return s
+	}
+	return color + s + reset
+}
@@ -0,0 +1,163 @@
+package diagnostics_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"pastel/diagnostics"
+	"pastel/token"
+)
+
+// pos returns a position in the test source.
+func pos(line, col int) token.Position {
+	return token.Position{Filename: "t.pas", Line: line, Column: col}
+}
+
+func TestRender(t *testing.T) {
+	const source = "program t;\nvar x: integer;\nbegin\n\tx := 'a'\nend."
+	tests := []struct {
+		name string
+		d    diagnostics.Diagnostic
+		want []string
+	}{
+		{
+			name: "caret under the span",
+			d: diagnostics.Diagnostic{
+				Kind:    "Parser Error",
+				Msg:     "Expected ';'",
+				Primary: diagnostics.Label{Pos: pos(2, 5), End: pos(2, 6)},
+			},
+			want: []string{
+				"[Parser Error] t.pas:2:5: Expected ';'",
+				"  |",
+				"2 | var x: integer;",
+				"  |     ^",
+			},
+		},
+		{
+			name: "tab kept before the caret",
+			d: diagnostics.Diagnostic{
+				Kind:    "Pascal Error",
+				Msg:     "Type mismatch",
+				Detail:  "Cannot assign a string to an integer.",
+				Hint:    "Assign an integer.",
+				Primary: diagnostics.Label{Pos: pos(4, 7), End: pos(4, 10)},
+			},
+			want: []string{
+				"[Pascal Error] t.pas:4:7: Type mismatch",
+				"  |",
+				"4 | \tx := 'a'",
+				"  | \t     ^^^",
+				"  → Cannot assign a string to an integer.",
+				"  💡 Hint: Assign an integer.",
+			},
+		},
+		{
+			name: "secondary label on an earlier line",
+			d: diagnostics.Diagnostic{
+				Kind:      "Pascal Error",
+				Msg:       "Type mismatch",
+				Primary:   diagnostics.Label{Pos: pos(4, 2), End: pos(4, 3)},
+				Secondary: []diagnostics.Label{{Pos: pos(2, 5), End: pos(2, 6), Msg: "declared here"}},
+			},
+			want: []string{
+				"[Pascal Error] t.pas:4:2: Type mismatch",
+				"  |",
+				"2 | var x: integer;",
+				"  |     - declared here",
+				"..",
+				"4 | \tx := 'a'",
+				"  | \t^",
+			},
+		},
+		{
+			name: "span over several lines underlined to the end of the first",
+			d: diagnostics.Diagnostic{
+				Kind:    "Parser Error",
+				Msg:     "Unterminated block",
+				Primary: diagnostics.Label{Pos: pos(3, 1), End: pos(5, 4)},
+			},
+			want: []string{
+				"[Parser Error] t.pas:3:1: Unterminated block",
+				"  |",
+				"3 | begin",
+				"  | ^^^^^",
+			},
+		},
+		{
+			name: "no position",
+			d: diagnostics.Diagnostic{
+				Kind: "Pascal Error",
+				Msg:  "Stack overflow",
+			},
+			want: []string{
+				"[Pascal Error] Stack overflow",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diagnostics.NewRenderer(source, false).Render(&tt.d)
+			if want := strings.Join(tt.want, "\n"); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestRenderColor(t *testing.T) {
+	d := &diagnostics.Diagnostic{Kind: "Pascal Error", Msg: "Division by zero"}
+	tests := []struct {
+		color bool
+		want  string
+	}{
+		{color: false, want: "[Pascal Error] Division by zero"},
+		{color: true, want: "\x1b[1;31m[Pascal Error]\x1b[0m \x1b[1mDivision by zero\x1b[0m"},
+	}
+
+	for _, tt := range tests {
+		if got := diagnostics.NewRenderer("", tt.color).Render(d); got != tt.want {
+			t.Errorf("color %v: got %q, want %q", tt.color, got, tt.want)
+		}
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	terminal, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer terminal.Close()
+	file, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	tests := []struct {
+		name    string
+		f       *os.File
+		noColor bool
+		want    bool
+	}{
+		{name: "character device", f: terminal, want: true},
+		{name: "character device with NO_COLOR", f: terminal, noColor: true, want: false},
+		{name: "regular file", f: file, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// t.Setenv restores NO_COLOR after the test, also when it is
+			// then unset.
+			t.Setenv("NO_COLOR", "")
+			if !tt.noColor {
+				os.Unsetenv("NO_COLOR")
+			}
+			if got := diagnostics.UseColor(tt.f); got != tt.want {
+				t.Errorf("UseColor = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
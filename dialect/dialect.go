@@ -0,0 +1,116 @@
+// Package dialect collects the switches that select between ISO 7185 Pascal
+// and the Turbo Pascal / Free Pascal extensions Pastel understands.
+package dialect
+
+import "strings"
+
+type Dialect struct {
+	Name string
+
+	// NestedComments lets a comment contain further comments of the same
+	// kind, as Free Pascal does. ISO and Turbo end a comment at the first
+	// closing delimiter.
+	NestedComments bool
+
+	// LineComments enables Delphi-style '//' comments that run to the end of
+	// the line.
+	LineComments bool
+
+	// CharCodes allows Turbo-style character codes in string literals, such
+	// as #13#10 for a carriage return and line feed.
+	CharCodes bool
+
+	// StringType provides Turbo Pascal's 'string' type, whose values are
+	// sequences of characters of any length, and the length function. ISO
+	// Pascal has only the fixed-length 'packed array[1..n] of char'.
+	StringType bool
+
+	// ShortCircuit stops evaluating 'and' and 'or' as soon as the left operand
+	// decides the result, like Turbo Pascal's {$B-}. Otherwise both operands
+	// are always evaluated.
+	ShortCircuit bool
+
+	// CaseElse allows an 'else' or 'otherwise' arm at the end of a case
+	// statement, taken when no label matches.
+	CaseElse bool
+
+	// UncheckedVariants lets a program read the fields of a record variant
+	// that is not the active one, as Turbo and Free Pascal do. Otherwise
+	// that is a runtime error.
+	UncheckedVariants bool
+
+	// TruncatedMod makes 'i mod j' take the sign of i, as Turbo and Free
+	// Pascal do, so that it is the remainder of 'i div j'. ISO requires j to
+	// be positive and the result to be in 0..j-1.
+	TruncatedMod bool
+
+	// IntWidth and BoolWidth are the field widths write uses for integers
+	// and booleans written without one. ISO leaves them to the
+	// implementation; 0 writes a value in as few characters as it needs.
+	IntWidth  int
+	BoolWidth int
+
+	// TruncateStrings makes write cut a string or boolean down to its field
+	// width, as ISO requires, instead of writing it whole when it is longer.
+	TruncateStrings bool
+
+	// ZeroWidth allows a field width or number of decimal places of 0 in
+	// write, as Turbo and Free Pascal do. ISO requires at least 1.
+	ZeroWidth bool
+}
+
+// ISO follows ISO 7185 Pascal as closely as Pastel can.
+var ISO = Dialect{
+	Name:            "iso",
+	IntWidth:        11,
+	BoolWidth:       5,
+	TruncateStrings: true,
+}
+
+// Turbo follows Turbo Pascal 7 / Delphi.
+var Turbo = Dialect{
+	Name:              "turbo",
+	LineComments:      true,
+	CharCodes:         true,
+	StringType:        true,
+	ShortCircuit:      true,
+	CaseElse:          true,
+	UncheckedVariants: true,
+	TruncatedMod:      true,
+	ZeroWidth:         true,
+}
+
+// FPC follows Free Pascal's default mode.
+var FPC = Dialect{
+	Name:              "fpc",
+	NestedComments:    true,
+	LineComments:      true,
+	CharCodes:         true,
+	StringType:        true,
+	ShortCircuit:      true,
+	CaseElse:          true,
+	UncheckedVariants: true,
+	TruncatedMod:      true,
+	ZeroWidth:         true,
+}
+
+// Pastel is the default: ISO semantics, plus extensions that cannot change
+// the meaning of a valid ISO program.
+var Pastel = Dialect{
+	Name:            "pastel",
+	LineComments:    true,
+	CharCodes:       true,
+	StringType:      true,
+	CaseElse:        true,
+	TruncateStrings: true,
+}
+
+// Lookup returns the dialect with the given name.
+func Lookup(name string) (Dialect, bool) {
+	for _, d := range []Dialect{Pastel, ISO, Turbo, FPC} {
+		if strings.EqualFold(d.Name, name) {
+			return d, true
+		}
+	}
+	return Dialect{}, false
+}
@@ -0,0 +1,30 @@
+package dialect_test
+
+import (
+	"testing"
+
+	"pastel/dialect"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{name: "pastel", want: "pastel", ok: true},
+		{name: "iso", want: "iso", ok: true},
+		{name: "ISO", want: "iso", ok: true},
+		{name: "Turbo", want: "turbo", ok: true},
+		{name: "fpc", want: "fpc", ok: true},
+		{name: "delphi", ok: false},
+		{name: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		d, ok := dialect.Lookup(tt.name)
+		if ok != tt.ok || d.Name != tt.want {
+			t.Errorf("Lookup(%q) = %q, %v, want %q, %v", tt.name, d.Name, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+program Alphabet;
+{ Builds the alphabet as a string, then prints the first letters with their
+  codes and capitals, and a rot13 message.
+
+  Expected output:
+    abcdefghijklmnopqrstuvwxyz
+    a  97 A
+    b  98 B
+    c  99 C
+    pastel's 'uryyb'
+    done }
+
+var
+  c: char;
+  s: string;
+
+function rot13(c: char): char;
+begin
+  if (c >= 'a') and (c <= 'z') then
+    rot13 := chr((ord(c) - ord('a') + 13) mod 26 + ord('a'))
+  else
+    rot13 := c
+end;
+
+begin
+  s := '';
+  for c := 'a' to 'z' do
+    s := s + c;
+  writeln(s);
+  for c := 'a' to 'c' do
+    writeln(c, ord(c):4, ' ', chr(ord(c) - ord('a') + ord('A')));
+  writeln('pastel''s ''', rot13('h'), rot13('e'), rot13('l'), rot13('l'), rot13('o'), '''');
+  write('done', #10)
+end.
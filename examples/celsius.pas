@@ -0,0 +1,27 @@
+program Celsius;
+{ Converts temperatures from Fahrenheit to Celsius, and averages them.
+
+  Expected output:
+     -40 -40.0
+      -4 -20.0
+      32   0.0
+      68  20.0
+     104  40.0
+    average  0.000000000000000E+000 }
+
+var
+  f: integer;
+  c, sum: real;
+
+begin
+  sum := 0;
+  f := -40;
+  while f <= 104 do
+  begin
+    c := (f - 32) * 5 / 9;
+    writeln(f:4, c:6:1);
+    sum := sum + c;
+    f := f + 36
+  end;
+  writeln('average ', sum / 5)
+end.
@@ -0,0 +1,32 @@
+program Gcd;
+{ Computes greatest common divisors with Euclid's algorithm, and the digit
+  sum of a number.
+
+  Expected output:
+    6
+    1
+    25 }
+
+var
+  n, sum: integer;
+
+function gcd(a, b: integer): integer;
+begin
+  if b = 0 then
+    gcd := a
+  else
+    gcd := gcd(b, a mod b)
+end;
+
+begin
+  writeln(gcd(48, 18));
+  writeln(gcd(17, 5));
+  n := 98341;
+  sum := 0;
+  while n > 0 do
+  begin
+    sum := sum + n mod 10;
+    n := n div 10
+  end;
+  writeln(sum)
+end.
@@ -0,0 +1,45 @@
+program List;
+{ A linked list built with new and disposed again. Run with -leaks to be
+  told about any node that is not disposed, e.g. after removing the last
+  loop.
+
+  Expected output:
+    3
+    2
+    1 }
+
+type
+  link = ^node;
+  node = record
+    value: integer;
+    next: link
+  end;
+
+var
+  head, p: link;
+  i: integer;
+
+begin
+  head := nil;
+  for i := 1 to 3 do
+  begin
+    new(p);
+    p^.value := i;
+    p^.next := head;
+    head := p
+  end;
+
+  p := head;
+  while p <> nil do
+  begin
+    writeln(p^.value);
+    p := p^.next
+  end;
+
+  while head <> nil do
+  begin
+    p := head;
+    head := head^.next;
+    dispose(p)
+  end
+end.
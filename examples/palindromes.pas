@@ -0,0 +1,66 @@
+program Palindromes;
+{ Checks which words read the same backwards, indexing each string by the
+  positions of its characters, and writes a fixed-length name kept in a
+  packed array of char, the string type of ISO Pascal.
+
+  Expected output:
+    level      is a palindrome
+    pastel     is not a palindrome
+    racecar    is a palindrome
+    x          is a palindrome
+    reversed: letsap
+    name: Ada, stored in 5 characters }
+
+type
+  name = packed array[1..5] of char;
+
+var
+  s: string;
+  n: name;
+
+function isPalindrome(s: string): boolean;
+var
+  i, j: integer;
+begin
+  i := 1;
+  j := length(s);
+  while (i < j) and (s[i] = s[j]) do
+  begin
+    i := i + 1;
+    j := j - 1
+  end;
+  isPalindrome := i >= j
+end;
+
+procedure check(s: string);
+begin
+  write(s, ' ':11 - length(s), 'is ');
+  if not isPalindrome(s) then
+    write('not ');
+  writeln('a palindrome')
+end;
+
+function reverse(s: string): string;
+var
+  i: integer;
+  c: char;
+begin
+  for i := 1 to length(s) div 2 do
+  begin
+    c := s[i];
+    s[i] := s[length(s) + 1 - i];
+    s[length(s) + 1 - i] := c
+  end;
+  reverse := s
+end;
+
+begin
+  check('level');
+  check('pastel');
+  check('racecar');
+  check('x');
+  s := reverse('pastel');
+  writeln('reversed: ', s);
+  n := 'Ada  ';
+  writeln('name: ', n:3, ', stored in ', length(n), ' characters')
+end.
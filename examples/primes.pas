@@ -0,0 +1,46 @@
+program Primes;
+{ The sieve of Eratosthenes, keeping the candidates in a set.
+
+  Expected output:
+    2
+    3
+    5
+    7
+    11
+    13
+    17
+    19
+    23
+    29
+    TRUE }
+
+const
+  max = 30;
+
+type
+  numbers = set of 2..max;
+
+var
+  sieve, odd: numbers;
+  i, j: integer;
+
+begin
+  sieve := [2..max];
+  for i := 2 to max do
+    if i in sieve then
+    begin
+      j := i + i;
+      while j <= max do
+      begin
+        sieve := sieve - [j];
+        j := j + i
+      end
+    end;
+
+  for i := 2 to max do
+    if i in sieve then
+      writeln(i);
+
+  odd := [3, 5, 7, 9, 11, 13, 15, 17, 19, 21, 23, 25, 27, 29];
+  writeln(sieve - [2] <= odd)
+end.
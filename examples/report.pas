@@ -0,0 +1,44 @@
+program Report;
+{ Writes a data file, then reads it back and reports on it. Run it from a
+  directory you can write to; it leaves scores.txt behind.
+
+  Expected output:
+    3 scores
+    total 225
+    best 90 }
+
+var
+  data: text;
+  n, score, total, best: integer;
+
+procedure writeScores(var f: text);
+begin
+  rewrite(f);
+  writeln(f, 70, ' ', 65);
+  writeln(f, 90)
+end;
+
+begin
+  assign(data, 'scores.txt');
+  writeScores(data);
+
+  reset(data);
+  n := 0;
+  total := 0;
+  best := 0;
+  while not eof(data) do
+  begin
+    read(data, score);
+    n := n + 1;
+    total := total + score;
+    if score > best then
+      best := score;
+    if eoln(data) then
+      readln(data)
+  end;
+  close(data);
+
+  writeln(n, ' scores');
+  writeln('total ', total);
+  writeln('best ', best)
+end.
@@ -0,0 +1,64 @@
+program Scopes;
+{ Nested routines reach the variables of every enclosing block through
+  the activation that declared them, even when calls recurse between
+  nesting levels.
+
+  Expected output:
+    1
+    0
+    2
+    10
+    3
+    20
+    3
+    15
+    7 }
+var calls, n: integer;
+
+procedure outer(n: integer);
+var level: integer;
+
+  procedure middle;
+  var x: integer;
+
+    procedure inner;
+    begin
+      level := level + 1;        { two blocks out }
+      x := n * 10;               { n is outer's parameter, not the global }
+      if n > 0 then outer(n - 1);
+      writeln(level)             { still this activation's level }
+    end;
+
+  begin
+    inner;
+    writeln(x)
+  end;
+
+begin
+  level := n;
+  middle;
+  calls := calls + 1
+end;
+
+function total(k: integer): integer;
+var i, acc: integer;
+
+  procedure add(v: integer);
+  begin
+    acc := acc + v;
+    total := acc                 { a nested routine may set the result }
+  end;
+
+begin
+  acc := 0;
+  for i := 1 to k do add(i)
+end;
+
+begin
+  n := 7;
+  calls := 0;
+  outer(2);
+  writeln(calls);
+  writeln(total(5));
+  writeln(n)
+end.
@@ -0,0 +1,41 @@
+program Seasons;
+{ Enumerated and subrange types: enumeration values work as for-loop
+  bounds and case labels, and succ/pred step through them in order.
+
+  Expected output:
+    winter
+    cold
+    spring
+    mild
+    summer
+    hot
+    autumn
+    mild
+    2
+    winter }
+
+const last = 3;
+
+type
+  season = (winter, spring, summer, autumn);
+  feel = (cold, mild, hot);
+  index = 0..last;
+
+var
+  s: season;
+  i: index;
+
+begin
+  for s := winter to autumn do
+  begin
+    writeln(s);
+    case s of
+      winter: writeln(cold);
+      spring, autumn: writeln(mild);
+      summer: writeln(hot)
+    end
+  end;
+  i := ord(pred(autumn));
+  writeln(i);
+  writeln(pred(spring))
+end.
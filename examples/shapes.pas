@@ -0,0 +1,39 @@
+program Shapes;
+{ Records nest inside records and arrays, are copied on assignment, and
+  'with' brings their fields into scope.
+
+  Expected output:
+    3
+    4
+    0
+    12 }
+
+type
+  point = record x, y: integer end;
+  rect = record topLeft, bottomRight: point end;
+
+var
+  r: rect;
+  corners: array[1..2] of point;
+  p: point;
+
+function area(r: rect): integer;
+begin
+  with r, topLeft do
+    area := (bottomRight.x - x) * (bottomRight.y - y)
+end;
+
+begin
+  with r.bottomRight do
+  begin
+    x := 3;
+    y := 4
+  end;
+  corners[1] := r.bottomRight;
+  p := corners[1];
+  corners[1].x := 0;
+  writeln(p.x);
+  writeln(p.y);
+  writeln(corners[1].x);
+  writeln(area(r))
+end.
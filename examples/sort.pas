@@ -0,0 +1,43 @@
+program Sort;
+{ Sorts an array in place through a var parameter, and shows that
+  assigning a whole array copies it.
+
+  Expected output:
+    1
+    2
+    3
+    5
+    8
+    5 }
+
+const n = 5;
+
+type
+  index = 1..n;
+  list = array[index] of integer;
+
+var
+  a, original: list;
+  i: index;
+
+procedure sort(var x: list);
+var i, j: index; t: integer;
+begin
+  for i := 1 to n - 1 do
+    for j := 1 to n - i do
+      if x[j] > x[j + 1] then
+      begin
+        t := x[j];
+        x[j] := x[j + 1];
+        x[j + 1] := t
+      end
+end;
+
+begin
+  a[1] := 5; a[2] := 3; a[3] := 8; a[4] := 1; a[5] := 2;
+  original := a;
+  sort(a);
+  for i := 1 to n do
+    writeln(a[i]);
+  writeln(original[1])
+end.
@@ -0,0 +1,22 @@
+program Table;
+{ Prints a multiplication table in columns four characters wide.
+
+  Expected output:
+       1   2   3   4
+       2   4   6   8
+       3   6   9  12
+       4   8  12  16
+    done }
+
+var
+  i, j: integer;
+
+begin
+  for i := 1 to 4 do
+  begin
+    for j := 1 to 4 do
+      write(i * j:4);
+    writeln
+  end;
+  writeln('done')
+end.
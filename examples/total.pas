@@ -0,0 +1,27 @@
+program Total(input, output);
+{ Reads integers from the standard input until it ends, and prints how
+  many there were and their total. For example, with the input
+
+    3 4
+    8
+
+  Expected output:
+    numbers? 3 numbers, total 15 }
+
+var
+  n, total, count: integer;
+
+begin
+  write('numbers? ');
+  total := 0;
+  count := 0;
+  while not eof do
+  begin
+    read(n);
+    total := total + n;
+    count := count + 1;
+    if eoln then
+      readln
+  end;
+  writeln(count, ' numbers, total ', total)
+end.
@@ -0,0 +1,46 @@
+program Variants;
+{ A variant record holds the fields of whichever variant its tag field
+  selects. Reading a field of another variant is an error, except under
+  -dialect turbo or fpc.
+
+  Expected output:
+    12
+    28
+    3 }
+
+type
+  kind = (circle, rect);
+  shape = record
+    id: integer;
+    case k: kind of
+      circle: (radius: integer);
+      rect: (w, h: integer)
+  end;
+
+var
+  shapes: array[1..2] of shape;
+  i: integer;
+
+function area(s: shape): integer;
+begin
+  case s.k of
+    circle: area := 3 * s.radius * s.radius;
+    rect: area := s.w * s.h
+  end
+end;
+
+begin
+  shapes[1].k := circle;
+  shapes[1].radius := 2;
+  with shapes[2] do
+  begin
+    k := rect;
+    w := 4;
+    h := 7
+  end;
+  for i := 1 to 2 do
+    writeln(area(shapes[i]));
+  shapes[2].k := circle;
+  shapes[2].radius := 1;
+  writeln(area(shapes[2]))
+end.
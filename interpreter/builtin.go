@@ -0,0 +1,214 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"pastel/parser"
+)
+
+// builtin is the value bound to the name of a required procedure or
+// function, such as ord: the parser's description of it, and its
+// implementation. Like any predeclared name, it can be hidden by a
+// declaration of the same name.
+type builtin struct {
+	*parser.RequiredRoutine
+	ordinal bool // the argument must be of an ordinal type
+	byRef   bool // the arguments are variables, passed to call as refs
+	call    func(args []Value, call parser.Node, env *Environment) (Value, error)
+}
+
+// builtins holds the implementation of each of parser.RequiredRoutines,
+// except read, readln and write, which are statements of their own and are
+// evaluated by evalRead and evalWrite.
+var builtins = map[string]builtin{
+	"ord":  {ordinal: true, call: builtinOrd},
+	"succ": {ordinal: true, call: builtinSucc},
+	"pred": {ordinal: true, call: builtinPred},
+	"chr":  {call: builtinChr},
+
+	"length": {call: builtinLength},
+
+	"trunc": {call: builtinTrunc},
+	"round": {call: builtinRound},
+
+	"new":     {byRef: true, call: builtinNew},
+	"dispose": {byRef: true, call: builtinDispose},
+
+	"assign":  {call: builtinAssign},
+	"reset":   {call: builtinReset},
+	"rewrite": {call: builtinRewrite},
+	"close":   {call: builtinClose},
+	"eof":     {call: builtinEOF},
+	"eoln":    {call: builtinEOLN},
+}
+
+// defineBuiltin binds the required routine r to its implementation in env.
+func (e *Environment) defineBuiltin(r *parser.RequiredRoutine) {
+	b := builtins[r.Name]
+	b.RequiredRoutine = r
+	e.Define(r.Name, b)
+}
+
+// callBuiltinFunction calls b for its result, as part of an expression.
+func callBuiltinFunction(b builtin, args []parser.Expr, call parser.Node, env *Environment) (Value, error) {
+	if !b.IsFunction {
+		return nil, &PascalError{
+			Msg:    fmt.Sprintf("Procedure '%s' used as a value", b.Name),
+			Detail: "A procedure does not return a result, so it cannot be used in an expression.",
+			Hint:   fmt.Sprintf("Call '%s' as a statement of its own.", b.Name),
+			Pos:    call.Pos(),
+			End:    call.End(),
+		}
+	}
+	return callBuiltin(b, args, call, env)
+}
+
+// callBuiltin evaluates the arguments of a call to b in env and calls it.
+func callBuiltin(b builtin, args []parser.Expr, call parser.Node, env *Environment) (Value, error) {
+	if len(args) != b.Params && !(b.ToInput && len(args) == 0) {
+		return nil, &PascalError{
+			Msg:    fmt.Sprintf("Wrong number of arguments in call to %s '%s'", b.Kind(), b.Name),
+			Detail: fmt.Sprintf("'%s' takes %s, but the call passes %d.", b.Name, plural(b.Params, "argument"), len(args)),
+			Hint:   "Pass exactly one argument for each parameter of the required " + b.Kind() + ".",
+			Pos:    call.Pos(),
+			End:    call.End(),
+		}
+	}
+
+	vals := make([]Value, len(args))
+	for i, arg := range args {
+		if b.byRef {
+			r, err := evalRef(arg, env)
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = r
+			continue
+		}
+		val, err := EvalExpr(arg, env)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = val
+	}
+
+	if b.ordinal {
+		if _, ok := ordinal(vals[0]); !ok {
+			return nil, &PascalError{
+				Msg:    fmt.Sprintf("Argument of '%s' must be of an ordinal type", b.Name),
+				Detail: fmt.Sprintf("The argument is %s.", typeName(vals[0])),
+				Hint:   "Pass an integer, boolean, char or enumerated value.",
+				Pos:    args[0].Pos(),
+				End:    args[0].End(),
+			}
+		}
+	}
+
+	return b.call(vals, call, env)
+}
+
+func builtinOrd(args []Value, call parser.Node, env *Environment) (Value, error) {
+	n, _ := ordinal(args[0])
+	return n, nil
+}
+
+// builtinChr returns the character whose ordinal number is the integer
+// args[0].
+func builtinChr(args []Value, call parser.Node, env *Environment) (Value, error) {
+	n, ok := args[0].(int)
+	if !ok {
+		return nil, &PascalError{
+			Msg:    "Argument of 'chr' must be an integer",
+			Detail: fmt.Sprintf("The argument is %s.", typeName(args[0])),
+			Hint:   "Pass the ordinal number of a character, e.g. 'chr(65)' for 'A'.",
+			Pos:    call.Pos(),
+			End:    call.End(),
+		}
+	}
+	if n < 0 || n > parser.MaxChar {
+		return nil, &PascalError{
+			Msg:    "'chr' out of range",
+			Detail: fmt.Sprintf("%d is not the ordinal number of a character; they run from 0 to %d.", n, parser.MaxChar),
+			Hint:   "Check the value before calling 'chr'.",
+			Pos:    call.Pos(),
+			End:    call.End(),
+		}
+	}
+	return char(n), nil
+}
+
+func builtinSucc(args []Value, call parser.Node, env *Environment) (Value, error) {
+	return step(args[0], 1, "succ", "last", "successor", call)
+}
+
+func builtinPred(args []Value, call parser.Node, env *Environment) (Value, error) {
+	return step(args[0], -1, "pred", "first", "predecessor", call)
+}
+
+// builtinLength returns the number of characters in the string args[0].
+func builtinLength(args []Value, call parser.Node, env *Environment) (Value, error) {
+	s, ok := asString(args[0])
+	if !ok {
+		return nil, &PascalError{
+			Msg:    "Argument of 'length' must be a string",
+			Detail: fmt.Sprintf("The argument is %s.", typeName(args[0])),
+			Hint:   "'length' counts the characters of a string.",
+			Pos:    call.Pos(),
+			End:    call.End(),
+		}
+	}
+	return len(s), nil
+}
+
+func builtinTrunc(args []Value, call parser.Node, env *Environment) (Value, error) {
+	return toInteger(args[0], math.Trunc, "trunc", call)
+}
+
+func builtinRound(args []Value, call parser.Node, env *Environment) (Value, error) {
+	// math.Round rounds halves away from zero, as ISO requires.
+	return toInteger(args[0], math.Round, "round", call)
+}
+
+// toInteger converts the real or integer v to an integer with the rounding
+// function conv, reporting an error if the result is not an integer value.
+func toInteger(v Value, conv func(float64) float64, name string, call parser.Node) (Value, error) {
+	x, ok := numeric(v)
+	if !ok {
+		return nil, &PascalError{
+			Msg:    fmt.Sprintf("Argument of '%s' must be a real", name),
+			Detail: fmt.Sprintf("The argument is %s.", typeName(v)),
+			Hint:   fmt.Sprintf("'%s' converts a real to an integer.", name),
+			Pos:    call.Pos(),
+			End:    call.End(),
+		}
+	}
+	x = conv(x)
+	if x <= math.MinInt64 || x >= math.MaxInt64 || math.IsNaN(x) {
+		return nil, &PascalError{
+			Msg:    fmt.Sprintf("'%s' out of range", name),
+			Detail: fmt.Sprintf("%s is too large to be converted to an integer.", formatValue(v)),
+			Hint:   "Check the value is no larger than maxint first.",
+			Pos:    call.Pos(),
+			End:    call.End(),
+		}
+	}
+	return int(x), nil
+}
+
+// step returns the value delta places after v in its type, or an error if
+// there is no such value.
+func step(v Value, delta int, name, end, noun string, call parser.Node) (Value, error) {
+	t := typeOf(v)
+	n, _ := ordinal(v)
+	low, high := parser.Bounds(t)
+	if (delta > 0 && n == high) || (delta < 0 && n == low) {
+		return nil, &PascalError{
+			Msg:    fmt.Sprintf("'%s' out of range", name),
+			Detail: fmt.Sprintf("%s is the %s value of %s, so it has no %s.", formatValue(v), end, t, noun),
+			Hint:   fmt.Sprintf("Check the value before calling '%s'.", name),
+			Pos:    call.Pos(),
+			End:    call.End(),
+		}
+	}
+	return fromOrdinal(t, n+delta), nil
+}
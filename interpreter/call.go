@@ -0,0 +1,279 @@
+package interpreter
+
+import (
+	"fmt"
+	"pastel/diagnostics"
+	"pastel/parser"
+	"strings"
+)
+
+// maxCallDepth bounds recursion so runaway programs fail with a Pascal
+// error instead of exhausting the Go stack.
+const maxCallDepth = 10000
+
+// lookupRoutine finds the procedure or function called name.
+func lookupRoutine(name string, at parser.Node, env *Environment) (routine, error) {
+	val, ok := env.Get(name)
+	if !ok {
+		return routine{}, &PascalError{
+			Msg:    fmt.Sprintf("Undeclared procedure or function '%s'", name),
+			Detail: "This name is called but no procedure or function with that name was declared.",
+			Hint:   "Check the spelling, or declare it before the 'begin' of the program.",
+			Pos:    at.Pos(),
+			End:    at.End(),
+		}
+	}
+	r, ok := val.(routine)
+	if !ok {
+		return routine{}, &PascalError{
+			Msg:    fmt.Sprintf("'%s' is not a procedure or function", name),
+			Detail: fmt.Sprintf("'%s' is a %s variable and cannot be called.", name, typeName(val)),
+			Hint:   "Only procedures and functions can be called.",
+			Pos:    at.Pos(),
+			End:    at.End(),
+		}
+	}
+	return r, nil
+}
+
+// callFunction calls r for its result, as part of an expression.
+func callFunction(r routine, args []parser.Expr, call parser.Node, env *Environment) (Value, error) {
+	if !r.decl.IsFunction {
+		return nil, &PascalError{
+			Msg:    fmt.Sprintf("Procedure '%s' used as a value", r.decl.Name),
+			Detail: "A procedure does not return a result, so it cannot be used in an expression.",
+			Hint:   fmt.Sprintf("Call '%s' as a statement of its own, or declare it as a function.", r.decl.Name),
+			Pos:    call.Pos(),
+			End:    call.End(),
+			Labels: declaredHere(r.decl),
+		}
+	}
+	return callRoutine(r, args, call, env)
+}
+
+// callRoutine calls r with the given arguments, evaluated in the caller's
+// environment env. Each call runs in a fresh activation frame holding the
+// parameters and the routine's own declarations. It returns the function
+// result, or nil for a procedure.
+func callRoutine(r routine, args []parser.Expr, call parser.Node, env *Environment) (Value, error) {
+	decl := r.decl
+
+	if len(args) != len(decl.Params) {
+		return nil, &PascalError{
+			Msg:    fmt.Sprintf("Wrong number of arguments in call to %s '%s'", decl.Kind(), decl.Name),
+			Detail: fmt.Sprintf("'%s' takes %s, but the call passes %d.", decl.Name, plural(len(decl.Params), "argument"), len(args)),
+			Hint:   "Pass exactly one argument for each parameter in the declaration.",
+			Pos:    call.Pos(),
+			End:    call.End(),
+			Labels: declaredHere(decl),
+		}
+	}
+
+	if env.depth >= maxCallDepth {
+		return nil, &PascalError{
+			Msg:    "Stack overflow",
+			Detail: fmt.Sprintf("More than %d calls were active when '%s' was called.", maxCallDepth, decl.Name),
+			Hint:   "Check that every recursive routine has a case that stops the recursion.",
+			Pos:    call.Pos(),
+			End:    call.End(),
+		}
+	}
+
+	frame := env.newFrame(r)
+	defer func() {
+		for _, c := range frame.held {
+			c.release()
+		}
+	}()
+
+	for i := range decl.Params {
+		if err := bindParam(frame, decl, i, args[i], env); err != nil {
+			return nil, err
+		}
+	}
+
+	evalDeclarations(decl.Declarations, frame)
+
+	if err := EvalStmt(decl.Body, frame); err != nil {
+		return nil, err
+	}
+
+	if decl.IsFunction && frame.result == nil {
+		return nil, &PascalError{
+			Msg:    fmt.Sprintf("Function '%s' did not assign a result", decl.Name),
+			Detail: "The function finished without assigning a value to its name.",
+			Hint:   fmt.Sprintf("Assign the result inside the function, e.g. '%s := ...;'.", decl.Name),
+			Pos:    call.Pos(),
+			End:    call.End(),
+			Labels: declaredHere(decl),
+		}
+	}
+	return frame.result, nil
+}
+
+// bindParam binds parameter i of decl in frame to its argument, evaluated in
+// the caller's environment env. Value parameters receive the argument's
+// value, var parameters a reference to the argument variable, and procedural
+// and functional parameters the routine the argument names.
+func bindParam(frame *Environment, decl *parser.RoutineDecl, i int, arg parser.Expr, env *Environment) error {
+	param := decl.Params[i]
+
+	mismatch := func(got string) error {
+		want := fmt.Sprint(param.Type)
+		if param.Routine != nil {
+			want = "a " + param.Routine.Kind()
+		}
+		return &PascalError{
+			Msg:    fmt.Sprintf("Type mismatch in argument %d of %s '%s'", i+1, decl.Kind(), decl.Name),
+			Detail: fmt.Sprintf("%s '%s' is %s, but the argument is %s.", capitalize(param.Kind()), param.Name, want, got),
+			Hint:   "Each argument must have the type of its parameter.",
+			Pos:    arg.Pos(),
+			End:    arg.End(),
+			Labels: []diagnostics.Label{{Pos: param.Pos(), End: param.End(), Msg: "parameter declared here"}},
+		}
+	}
+
+	switch {
+	case param.Routine != nil:
+		ident, ok := arg.(*parser.Identifier)
+		if !ok {
+			return mismatch("an expression")
+		}
+		val, ok := env.Get(ident.Value)
+		if !ok {
+			_, err := lookupRoutine(ident.Value, ident, env)
+			return err
+		}
+		r, ok := val.(routine)
+		if !ok {
+			return mismatch(typeName(val))
+		}
+		if !congruent(param.Routine, r.decl) {
+			return &PascalError{
+				Msg:    fmt.Sprintf("Incompatible %s passed to '%s'", r.decl.Kind(), decl.Name),
+				Detail: fmt.Sprintf("The heading of '%s' does not match that of %s '%s'.", r.decl.Name, param.Kind(), param.Name),
+				Hint:   "The routine passed must have the same kinds and types of parameters, and the same result type.",
+				Pos:    arg.Pos(),
+				End:    arg.End(),
+				Labels: []diagnostics.Label{
+					{Pos: param.Pos(), End: param.End(), Msg: "parameter declared here"},
+					{Pos: r.decl.NameSpan.Pos(), End: r.decl.NameSpan.End(), Msg: fmt.Sprintf("'%s' declared here", r.decl.Name)},
+				},
+			}
+		}
+		frame.Define(param.Name, r)
+
+	case param.Var:
+		target, err := evalRef(arg, env)
+		if err != nil {
+			return err
+		}
+		// A variable passed by reference must have exactly the type of the
+		// parameter, not merely a compatible one.
+		if target.typ() != param.Type {
+			return mismatch(fmt.Sprint(target.typ()))
+		}
+		if _, ok := target.(charRef); ok {
+			return &PascalError{
+				Msg:    fmt.Sprintf("Character of a string passed to var parameter '%s'", param.Name),
+				Detail: "A string can change length while the parameter refers to one of its characters.",
+				Hint:   "Copy the character into a char variable, and pass that instead.",
+				Pos:    arg.Pos(),
+				End:    arg.End(),
+			}
+		}
+		// A heap variable cannot be disposed while it is passed by reference.
+		if c := heapCell(target); c != nil {
+			c.hold(arg)
+			frame.held = append(frame.held, c)
+		}
+		frame.declareVar(param.Name, param.Type, target)
+
+	default:
+		val, err := EvalExpr(arg, env)
+		if err != nil {
+			return err
+		}
+		if !compatible(param.Type, val) {
+			return mismatch(typeName(val))
+		}
+		if err := checkRange(param.Type, val, arg, fmt.Sprintf("parameter '%s'", param.Name)); err != nil {
+			return err
+		}
+		frame.declareVar(param.Name, param.Type, storedValue(param.Type, val))
+	}
+
+	return nil
+}
+
+// congruent reports whether the routine headings a and b have matching
+// parameter lists and result types, as ISO Pascal requires of a routine
+// passed to a procedural or functional parameter.
+func congruent(a, b *parser.RoutineDecl) bool {
+	if a.IsFunction != b.IsFunction || a.ResultType != b.ResultType || len(a.Params) != len(b.Params) {
+		return false
+	}
+	for i, pa := range a.Params {
+		pb := b.Params[i]
+		if pa.Var != pb.Var || pa.Type != pb.Type || (pa.Routine == nil) != (pb.Routine == nil) {
+			return false
+		}
+		if pa.Routine != nil && !congruent(pa.Routine, pb.Routine) {
+			return false
+		}
+	}
+	return true
+}
+
+// assignResult handles an assignment to the name of a function, which sets
+// the result of the function's innermost running activation. A routine
+// nested in the function may assign it too.
+func assignResult(s *parser.AssignStmt, r routine, val Value, env *Environment) error {
+	decl := r.decl
+	frame := env.activation(decl)
+	if !decl.IsFunction || frame == nil {
+		return &PascalError{
+			Msg:    fmt.Sprintf("Cannot assign to %s '%s'", decl.Kind(), decl.Name),
+			Detail: "Only a function can assign its own name, which sets the function's result.",
+			Hint:   "Assign to a variable instead.",
+			Pos:    s.Pos(),
+			End:    s.End(),
+			Labels: declaredHere(decl),
+		}
+	}
+	if !compatible(decl.ResultType, val) {
+		return &PascalError{
+			Msg:    fmt.Sprintf("Type mismatch in result of function '%s'", decl.Name),
+			Detail: fmt.Sprintf("The function returns %s, but the value assigned is %s.", decl.ResultType, typeName(val)),
+			Hint:   "The value assigned to a function's name must have the function's result type.",
+			Pos:    s.Value.Pos(),
+			End:    s.Value.End(),
+			Labels: declaredHere(decl),
+		}
+	}
+	if err := checkRange(decl.ResultType, val, s.Value, fmt.Sprintf("the result of '%s'", decl.Name)); err != nil {
+		return err
+	}
+	frame.result = storedValue(decl.ResultType, val)
+	return nil
+}
+
+// declaredHere labels the heading of decl, for errors about calls to it.
+func declaredHere(decl *parser.RoutineDecl) []diagnostics.Label {
+	return []diagnostics.Label{{Pos: decl.NameSpan.Pos(), End: decl.NameSpan.End(), Msg: fmt.Sprintf("'%s' declared here", decl.Name)}}
+}
+
+// plural returns "1 argument", "2 arguments" and so on.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
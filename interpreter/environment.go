@@ -1,23 +1,236 @@
 package interpreter
 
+import (
+	"io"
+	"os"
+	"pastel/dialect"
+	"pastel/parser"
+)
+
 type Environment struct {
-	store map[string]int
+	store   map[string]Value
+	types   map[string]parser.Type // declared types of the variables in store
+	dialect dialect.Dialect
+
+	// outer is the lexically enclosing environment: for a call, the frame of
+	// the routine that declares the called routine. It is nil for the
+	// program's environment.
+	outer *Environment
+
+	// routine is the procedure or function this frame was created for, and
+	// result holds a function's result once it has been assigned.
+	routine *parser.RoutineDecl
+	result  Value
+	depth   int // number of active calls, for stack overflow detection
+
+	// controls maps the control variables of running for loops to their loop.
+	controls map[string]*parser.ForStmt
+
+	// held holds the heap variables passed to the var parameters of this
+	// frame, which are released when the call returns.
+	held []*cell
+
+	// heap holds the variables created by new, files the files that are
+	// open, and input and output the standard files. They are shared by
+	// every environment of the program.
+	heap          *heap
+	files         map[*file]bool
+	input, output *file
 }
 
+// NewEnviroment creates the environment for a program, holding the
+// predeclared constants.
 func NewEnviroment() *Environment {
-	return &Environment{store: make(map[string]int)}
+	env := newEnvironment()
+	env.heap = newHeap()
+	env.files = make(map[*file]bool)
+	env.input = stdFile("input", os.Stdin, nil)
+	env.output = stdFile("output", nil, os.Stdout)
+	env.declareVar("input", parser.Text, env.input)
+	env.declareVar("output", parser.Text, env.output)
+	for _, decl := range parser.Universe {
+		env.defineConst(decl)
+	}
+	for _, r := range parser.RequiredRoutines {
+		env.defineBuiltin(r)
+	}
+	return env
+}
+
+func newEnvironment() *Environment {
+	return &Environment{
+		store:    make(map[string]Value),
+		types:    make(map[string]parser.Type),
+		dialect:  dialect.Pastel,
+		controls: make(map[string]*parser.ForStmt),
+	}
+}
+
+// SetDialect selects the language dialect the program is evaluated under.
+func (e *Environment) SetDialect(d dialect.Dialect) {
+	e.dialect = d
+	for _, r := range parser.RequiredRoutines {
+		if r.StringType && !d.StringType {
+			delete(e.store, r.Name)
+		} else {
+			e.defineBuiltin(r)
+		}
+	}
 }
 
-func (e *Environment) Set(name string, value int) {
+// SetInput makes the program read its standard input from r, so that input
+// can be supplied by tests and embedders.
+func (e *Environment) SetInput(r io.Reader) {
+	*e.input = *stdFile("input", r, nil)
+}
+
+// SetOutput makes the program write its standard output to w.
+func (e *Environment) SetOutput(w io.Writer) {
+	*e.output = *stdFile("output", nil, w)
+}
+
+// NewEnclosedEnvironment creates an environment nested inside outer. Names
+// not defined in it are looked up in outer, and so on outwards.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := newEnvironment()
+	env.dialect = outer.dialect
+	env.outer = outer
+	env.depth = outer.depth
+	env.heap = outer.heap
+	env.files = outer.files
+	env.input, env.output = outer.input, outer.output
+	return env
+}
+
+// newFrame creates the activation frame for a call of r made from e. The
+// frame is nested in the environment r was declared in, not in e, so the
+// routine sees the variables of its enclosing blocks whoever calls it.
+func (e *Environment) newFrame(r routine) *Environment {
+	frame := NewEnclosedEnvironment(r.env)
+	frame.routine = r.decl
+	frame.depth = e.depth + 1
+	return frame
+}
+
+// Define binds name in this environment, hiding any outer name it shadows.
+func (e *Environment) Define(name string, value Value) {
 	e.store[name] = value
 }
 
-func (e *Environment) Get(name string) (int, bool) {
-	val, ok := e.store[name]
-	return val, ok
+// declareVar binds the variable name of type t in this environment, with
+// the initial value val.
+func (e *Environment) declareVar(name string, t parser.Type, val Value) {
+	e.store[name] = val
+	e.types[name] = t
+}
+
+// varType returns the declared type of the variable name, or nil if name is
+// not a variable.
+func (e *Environment) varType(name string) parser.Type {
+	if owner := e.owner(name); owner != nil {
+		return owner.types[name]
+	}
+	return nil
+}
+
+// defineConst binds the constant declared by decl in this environment.
+func (e *Environment) defineConst(decl *parser.ConstDecl) {
+	switch lit := decl.Value.(type) {
+	case *parser.IntegerLiteral:
+		e.Define(decl.Name, constant{decl: decl, val: lit.Value})
+	case *parser.RealLiteral:
+		e.Define(decl.Name, constant{decl: decl, val: lit.Value})
+	case *parser.StringLiteral:
+		e.Define(decl.Name, constant{decl: decl, val: stringValue(lit.Value)})
+	case *parser.BooleanLiteral:
+		e.Define(decl.Name, constant{decl: decl, val: lit.Value})
+	case *parser.EnumLiteral:
+		e.Define(decl.Name, constant{decl: decl, val: enum{typ: lit.Type, ord: lit.Ord}})
+	}
+}
+
+// Set assigns to the variable name where it is defined, or defines it here
+// if it is not defined at all. Assigning to a var parameter assigns to the
+// variable it refers to.
+func (e *Environment) Set(name string, value Value) {
+	if owner := e.owner(name); owner != nil {
+		if r, ok := owner.store[name].(ref); ok {
+			r.set(value)
+			return
+		}
+		owner.store[name] = value
+		return
+	}
+	e.store[name] = value
+}
+
+func (e *Environment) Get(name string) (Value, bool) {
+	if owner := e.owner(name); owner != nil {
+		switch v := owner.store[name].(type) {
+		case ref:
+			return v.get(), true
+		case constant:
+			return v.val, true
+		}
+		return owner.store[name], true
+	}
+	return nil, false
+}
+
+// constant returns the declaration of name if it names a constant.
+func (e *Environment) constant(name string) *parser.ConstDecl {
+	if owner := e.owner(name); owner != nil {
+		if c, ok := owner.store[name].(constant); ok {
+			return c.decl
+		}
+	}
+	return nil
 }
 
 func (e *Environment) Exists(name string) bool {
-	_, ok := e.store[name]
-	return ok
+	return e.owner(name) != nil
+}
+
+// owner returns the innermost environment, starting from e, that defines
+// name. It returns nil if name is not defined.
+func (e *Environment) owner(name string) *Environment {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			return env
+		}
+	}
+	return nil
+}
+
+// activation returns the innermost frame of a running call of decl that is
+// visible from e, or nil.
+func (e *Environment) activation(decl *parser.RoutineDecl) *Environment {
+	for env := e; env != nil; env = env.outer {
+		if env.routine == decl {
+			return env
+		}
+	}
+	return nil
+}
+
+// lockControl marks name as the control variable of the running loop.
+func (e *Environment) lockControl(name string, loop *parser.ForStmt) {
+	if owner := e.owner(name); owner != nil {
+		owner.controls[name] = loop
+	}
+}
+
+func (e *Environment) unlockControl(name string) {
+	if owner := e.owner(name); owner != nil {
+		delete(owner.controls, name)
+	}
+}
+
+// controlledBy returns the running for loop whose control variable is name,
+// or nil.
+func (e *Environment) controlledBy(name string) *parser.ForStmt {
+	if owner := e.owner(name); owner != nil {
+		return owner.controls[name]
+	}
+	return nil
 }
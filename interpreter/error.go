@@ -1,20 +1,31 @@
 package interpreter
 
-import "fmt"
+import (
+	"pastel/diagnostics"
+	"pastel/token"
+)
 
 type PascalError struct {
 	Msg    string
 	Detail string
 	Hint   string
+	Pos    token.Position
+	End    token.Position
+	Labels []diagnostics.Label // related locations, e.g. a declaration
 }
 
-func (e *PascalError) Error() string {
-	msg := fmt.Sprintf("\n[Pascal Error] %s", e.Msg)
-	if e.Detail != "" {
-		msg += fmt.Sprintf("\n  → %s", e.Detail)
-	}
-	if e.Hint != "" {
-		msg += fmt.Sprintf("\n  💡 Hint: %s", e.Hint)
+// Diagnostic describes the error for the diagnostics renderer.
+func (e *PascalError) Diagnostic() *diagnostics.Diagnostic {
+	return &diagnostics.Diagnostic{
+		Kind:      "Pascal Error",
+		Msg:       e.Msg,
+		Detail:    e.Detail,
+		Hint:      e.Hint,
+		Primary:   diagnostics.Label{Pos: e.Pos, End: e.End},
+		Secondary: e.Labels,
 	}
-	return msg
+}
+
+func (e *PascalError) Error() string {
+	return "\n" + diagnostics.Format(e.Diagnostic())
 }
@@ -0,0 +1,123 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pastel/dialect"
+	"pastel/interpreter"
+	"pastel/lexer"
+	"pastel/parser"
+)
+
+// TestExamples runs every program in the examples directory and compares
+// what it writes with the "Expected output:" block of its header comment.
+// A header may also give the standard input, indented after a line ending
+// in "with the input".
+func TestExamples(t *testing.T) {
+	paths, err := filepath.Glob("../examples/*.pas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no examples found")
+	}
+
+	for _, path := range paths {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			input, want, ok := expectations(string(src))
+			if !ok {
+				t.Fatal("header comment has no \"Expected output:\" block")
+			}
+
+			// Some examples write files of their own.
+			t.Chdir(t.TempDir())
+
+			got, err := run(path, string(src), input)
+			if err != nil {
+				t.Fatalf("%v\noutput so far:\n%s", err, got)
+			}
+			if got != want {
+				t.Errorf("output differs\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// run parses and runs the program src with the given standard input, and
+// returns what it wrote to the standard output.
+func run(filename, src, input string) (string, error) {
+	return runDialect(dialect.Pastel, filename, src, input)
+}
+
+// runDialect is run for a program in the dialect d.
+func runDialect(d dialect.Dialect, filename, src, input string) (string, error) {
+	p := parser.New(lexer.NewFile(filename, src, d))
+	prog := p.ParseProgram()
+	if p.HasErrors() {
+		return "", p.Errors()[0]
+	}
+
+	var out bytes.Buffer
+	env := interpreter.NewEnviroment()
+	env.SetDialect(d)
+	env.SetInput(strings.NewReader(input))
+	env.SetOutput(&out)
+	err := interpreter.EvalProgram(prog, env)
+	return out.String(), err
+}
+
+// expectations returns the standard input and expected output given in the
+// header comment of src. Each block is indented two columns further than
+// the line that introduces it; the comment's closing brace ends the output.
+func expectations(src string) (input, output string, ok bool) {
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasSuffix(trimmed, "with the input"):
+			input = block(lines[i+1:], indent(line)+2, "Expected output:")
+		case trimmed == "Expected output:":
+			output = block(lines[i+1:], indent(line)+2, "")
+			return input, output, true
+		}
+	}
+	return "", "", false
+}
+
+// block collects the lines of a block indented by n columns, up to the
+// line that starts with stop or, if stop is empty, up to the closing brace
+// of the comment. Blank lines around the block are dropped.
+func block(lines []string, n int, stop string) string {
+	var b strings.Builder
+	for _, line := range lines {
+		if stop != "" && strings.HasPrefix(strings.TrimSpace(line), stop) {
+			break
+		}
+		last := stop == "" && strings.HasSuffix(line, "}")
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "}"), " ")
+		if len(line) > n {
+			b.WriteString(line[n:])
+		}
+		b.WriteString("\n")
+		if last {
+			break
+		}
+	}
+	return strings.TrimLeft(strings.TrimRight(b.String(), "\n")+"\n", "\n")
+}
+
+func indent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
@@ -0,0 +1,879 @@
+package interpreter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"pastel/parser"
+	"strconv"
+	"strings"
+)
+
+// file is the value of a file variable. Files are never copied, as they
+// cannot be assigned or passed by value, so every use of the variable
+// shares the one file.
+type file struct {
+	typ    *parser.FileType
+	path   string // the name given by assign, or "" before assign is called
+	mode   fileMode
+	opened parser.Node // the call of reset or rewrite that opened the file
+	os     *os.File
+	r      *bufio.Reader
+	w      *bufio.Writer
+	line   int // line number of the next character read from a text file
+
+	// std is "input" or "output" for the files bound to the standard input
+	// and output, and "" for other files.
+	std string
+}
+
+// stdFile returns the standard input or output file, reading from r or
+// writing to w.
+func stdFile(name string, r io.Reader, w io.Writer) *file {
+	f := &file{typ: parser.Text, std: name, line: 1}
+	if r != nil {
+		f.r, f.mode = bufio.NewReader(r), reading
+	}
+	if w != nil {
+		f.w, f.mode = bufio.NewWriter(w), writing
+	}
+	return f
+}
+
+// name describes f for messages, e.g. "'data.txt'" or "the standard input".
+func (f *file) name() string {
+	if f.std != "" {
+		return "the standard " + f.std
+	}
+	return "'" + f.path + "'"
+}
+
+type fileMode int
+
+const (
+	closed  fileMode = iota
+	reading          // opened by reset
+	writing          // opened by rewrite
+)
+
+func (m fileMode) String() string {
+	switch m {
+	case reading:
+		return "reading"
+	case writing:
+		return "writing"
+	default:
+		return "closed"
+	}
+}
+
+// isText reports whether f is a text file, made up of lines of characters
+// rather than components of a type.
+func (f *file) isText() bool {
+	return f.typ.Elem == nil
+}
+
+// fileArg returns v, the argument of the required procedure or function
+// name, as a file, or an error if it is not one.
+func fileArg(name string, v Value, call parser.Node) (*file, error) {
+	f, ok := v.(*file)
+	if !ok {
+		return nil, &PascalError{
+			Msg:    fmt.Sprintf("Argument of '%s' must be a file variable", name),
+			Detail: fmt.Sprintf("The argument is %s.", typeName(v)),
+			Hint:   "Pass a variable of type text, or of a 'file of' type.",
+			Pos:    call.Pos(),
+			End:    call.End(),
+		}
+	}
+	return f, nil
+}
+
+// builtinAssign gives the file variable args[0] the path args[1], which
+// reset and rewrite open.
+func builtinAssign(args []Value, call parser.Node, env *Environment) (Value, error) {
+	f, err := fileArg("assign", args[0], call)
+	if err != nil {
+		return nil, err
+	}
+	path, ok := asString(args[1])
+	if !ok {
+		return nil, &PascalError{
+			Msg:    "File name must be a string",
+			Detail: fmt.Sprintf("The second argument of 'assign' is %s.", typeName(args[1])),
+			Hint:   "Name the file with a string, e.g. assign(f, 'data.txt').",
+			Pos:    call.Pos(),
+			End:    call.End(),
+		}
+	}
+	if err := f.close(call, env); err != nil {
+		return nil, err
+	}
+	f.path = path
+	return nil, nil
+}
+
+// builtinReset opens the file args[0] for reading from its start.
+func builtinReset(args []Value, call parser.Node, env *Environment) (Value, error) {
+	f, err := openArg("reset", args[0], call, env)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, ioError(fmt.Sprintf("Cannot open '%s' for reading", f.path), err, call)
+	}
+	f.os, f.r, f.mode, f.opened, f.line = file, bufio.NewReader(file), reading, call, 1
+	env.files[f] = true
+	return nil, nil
+}
+
+// builtinRewrite creates the file args[0], or empties it if it exists, and
+// opens it for writing.
+func builtinRewrite(args []Value, call parser.Node, env *Environment) (Value, error) {
+	f, err := openArg("rewrite", args[0], call, env)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Create(f.path)
+	if err != nil {
+		return nil, ioError(fmt.Sprintf("Cannot open '%s' for writing", f.path), err, call)
+	}
+	f.os, f.w, f.mode, f.opened = file, bufio.NewWriter(file), writing, call
+	env.files[f] = true
+	return nil, nil
+}
+
+// openArg returns the file argument of reset or rewrite, closing it first
+// if it is open.
+func openArg(name string, v Value, call parser.Node, env *Environment) (*file, error) {
+	f, err := fileArg(name, v, call)
+	if err != nil {
+		return nil, err
+	}
+	if f.path == "" {
+		return nil, &PascalError{
+			Msg:    "File has no name",
+			Detail: fmt.Sprintf("'%s' needs to know which file to open, but none has been assigned.", name),
+			Hint:   "Call assign first, e.g. assign(f, 'data.txt').",
+			Pos:    call.Pos(),
+			End:    call.End(),
+		}
+	}
+	if err := f.close(call, env); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// builtinClose closes the file args[0], writing out anything still
+// buffered.
+func builtinClose(args []Value, call parser.Node, env *Environment) (Value, error) {
+	f, err := fileArg("close", args[0], call)
+	if err != nil {
+		return nil, err
+	}
+	return nil, f.close(call, env)
+}
+
+// close closes f if it is open. Errors writing out the buffered output are
+// reported at at.
+func (f *file) close(at parser.Node, env *Environment) error {
+	if f.mode == closed {
+		return nil
+	}
+
+	var err error
+	if f.mode == writing {
+		err = f.w.Flush()
+	}
+	// The standard files stay open, so closing them only writes out the
+	// buffered output.
+	if f.std == "" {
+		delete(env.files, f)
+		if cerr := f.os.Close(); err == nil {
+			err = cerr
+		}
+		f.mode, f.os, f.r, f.w = closed, nil, nil, nil
+	}
+	if err != nil {
+		return ioError(fmt.Sprintf("Cannot write to %s", f.name()), err, at)
+	}
+	return nil
+}
+
+// closeFiles closes the files the program left open, and writes out what
+// is buffered for the standard output.
+func (e *Environment) closeFiles(prog *parser.Program) error {
+	var first error
+	for f := range e.files {
+		if err := f.close(f.opened, e); err != nil && first == nil {
+			first = err
+		}
+	}
+	if err := e.output.close(prog, e); err != nil && first == nil {
+		first = err
+	}
+	return first
+}
+
+// flushOutput writes out what is buffered for the standard output, so that
+// a prompt appears before the program waits for input.
+func (e *Environment) flushOutput(at parser.Node) error {
+	if err := e.output.w.Flush(); err != nil {
+		return writeError(e.output, err, at)
+	}
+	return nil
+}
+
+// fileOrInput returns the file argument of the required function name, or
+// the standard input if the call has no arguments.
+func fileOrInput(name string, args []Value, call parser.Node, env *Environment) (*file, error) {
+	if len(args) == 0 {
+		return env.input, nil
+	}
+	return fileArg(name, args[0], call)
+}
+
+// builtinEOF reports whether the file args[0], which is open for reading,
+// has no more characters or components to read.
+func builtinEOF(args []Value, call parser.Node, env *Environment) (Value, error) {
+	f, err := fileOrInput("eof", args, call, env)
+	if err != nil {
+		return nil, err
+	}
+	if err := readable(f, call, env); err != nil {
+		return nil, err
+	}
+	_, err = f.r.Peek(1)
+	if err == io.EOF {
+		return true, nil
+	}
+	if err != nil {
+		return nil, ioError(fmt.Sprintf("Cannot read from %s", f.name()), err, call)
+	}
+	return false, nil
+}
+
+// builtinEOLN reports whether the text file args[0] is at the end of a
+// line, or of the file.
+func builtinEOLN(args []Value, call parser.Node, env *Environment) (Value, error) {
+	f, err := fileOrInput("eoln", args, call, env)
+	if err != nil {
+		return nil, err
+	}
+	if err := readable(f, call, env); err != nil {
+		return nil, err
+	}
+	if !f.isText() {
+		return nil, textOnlyError("eoln", f, call)
+	}
+	b, err := f.r.Peek(1)
+	if err == io.EOF {
+		return true, nil
+	}
+	if err != nil {
+		return nil, ioError(fmt.Sprintf("Cannot read from %s", f.name()), err, call)
+	}
+	return b[0] == '\n' || b[0] == '\r', nil
+}
+
+// readable reports an error if f is not open for reading. Before the
+// standard input is read, the standard output is flushed.
+func readable(f *file, at parser.Node, env *Environment) error {
+	if f.mode != reading {
+		return modeError(f, reading, "reset", at)
+	}
+	if f.std != "" {
+		return env.flushOutput(at)
+	}
+	return nil
+}
+
+// evalWrite writes the arguments of a write or writeln statement to the
+// standard output, or to the file the statement names.
+func evalWrite(s *parser.PrintStmt, env *Environment) error {
+	f := env.output
+	if s.File != nil {
+		val, err := EvalExpr(s.File, env)
+		if err != nil {
+			return err
+		}
+		if f, err = fileArg(stmtName(s.Newline, "write"), val, s.File); err != nil {
+			return err
+		}
+		if f.mode != writing {
+			return modeError(f, writing, "rewrite", s.File)
+		}
+		if !f.isText() {
+			return writeComponents(s, f, env)
+		}
+	}
+
+	for _, arg := range s.Args {
+		text, err := formatArg(arg, env)
+		if err != nil {
+			return err
+		}
+		if _, err := f.w.WriteString(text); err != nil {
+			return writeError(f, err, s)
+		}
+	}
+	if s.Newline {
+		if err := f.w.WriteByte('\n'); err != nil {
+			return writeError(f, err, s)
+		}
+	}
+	return nil
+}
+
+// writeComponents writes the arguments of s to the binary file f, as one
+// component each.
+func writeComponents(s *parser.PrintStmt, f *file, env *Environment) error {
+	if s.Newline {
+		return textOnlyError("writeln", f, s)
+	}
+	for _, wa := range s.Args {
+		arg := wa.Value
+		if wa.Width != nil {
+			return &PascalError{
+				Msg:    "Field width in write to a binary file",
+				Detail: fmt.Sprintf("%s holds %s components rather than text, so they have no width.", capitalize(f.name()), f.typ.Elem),
+				Hint:   "Remove the ':' and what follows it.",
+				Pos:    wa.Width.Pos(),
+				End:    wa.End(),
+			}
+		}
+		val, err := EvalExpr(arg, env)
+		if err != nil {
+			return err
+		}
+		if !compatible(f.typ.Elem, val) {
+			return &PascalError{
+				Msg:    "Type mismatch in write",
+				Detail: fmt.Sprintf("'%s' is a %s, but the value written is %s.", rootName(s.File), f.typ, typeName(val)),
+				Hint:   "Write values of the file's component type.",
+				Pos:    arg.Pos(),
+				End:    arg.End(),
+			}
+		}
+		if err := checkRange(f.typ.Elem, val, arg, "a component of '"+rootName(s.File)+"'"); err != nil {
+			return err
+		}
+		if err := encodeValue(f.w, storedValue(f.typ.Elem, val)); err != nil {
+			if errors.Is(err, errUndefined) {
+				return &PascalError{
+					Msg:    "Value written is undefined",
+					Detail: fmt.Sprintf("Part of the value written to %s has not been assigned.", f.name()),
+					Hint:   "Assign every part of the value before writing it.",
+					Pos:    arg.Pos(),
+					End:    arg.End(),
+				}
+			}
+			return writeError(f, err, s)
+		}
+	}
+	return nil
+}
+
+// evalRead reads values into the arguments of a read or readln statement
+// from the standard input, or from the file the statement names.
+func evalRead(s *parser.ReadStmt, env *Environment) error {
+	f := env.input
+	var at parser.Node = s
+	if s.File != nil {
+		val, err := EvalExpr(s.File, env)
+		if err != nil {
+			return err
+		}
+		if f, err = fileArg(stmtName(s.Newline, "read"), val, s.File); err != nil {
+			return err
+		}
+		at = s.File
+	}
+	if err := readable(f, at, env); err != nil {
+		return err
+	}
+	if s.Newline && !f.isText() {
+		return textOnlyError("readln", f, s)
+	}
+
+	for _, arg := range s.Args {
+		target, err := evalRef(arg, env)
+		if err != nil {
+			return err
+		}
+		var val Value
+		if f.isText() {
+			val, err = readText(f, target.typ(), arg)
+		} else {
+			val, err = readComponent(f, target.typ(), arg)
+		}
+		if err != nil {
+			return err
+		}
+		if err := checkRange(target.typ(), val, arg, describe(arg)); err != nil {
+			return err
+		}
+		if err := checkVariant(target, arg); err != nil {
+			return err
+		}
+		target.set(storedValue(target.typ(), val))
+	}
+
+	if s.Newline {
+		return skipLine(f, s)
+	}
+	return nil
+}
+
+// readText reads a value of type t for the variable access arg from the
+// text file f.
+func readText(f *file, t parser.Type, arg parser.Expr) (Value, error) {
+	switch parser.Host(t) {
+	case parser.Integer, parser.Real:
+		return readNumber(f, t == parser.Real, arg)
+	case parser.Char:
+		return readChar(f, arg)
+	case parser.String:
+		return readString(f, arg)
+	}
+	return nil, &PascalError{
+		Msg:    fmt.Sprintf("Cannot read a value of type %s from a text file", t),
+		Detail: fmt.Sprintf("%s is of type %s, but only integers, reals, characters and strings can be read as text.", capitalize(describe(arg)), t),
+		Hint:   "Read the value as an integer or string and convert it.",
+		Pos:    arg.Pos(),
+		End:    arg.End(),
+	}
+}
+
+// readChar reads the next character of the text file f. The end of a line
+// reads as a space, as in ISO Pascal.
+func readChar(f *file, arg parser.Expr) (Value, error) {
+	b, err := f.peekByte()
+	if err == io.EOF {
+		return nil, &PascalError{
+			Msg:    "Read past end of file",
+			Detail: fmt.Sprintf("There is no character left to read in %s.", f.name()),
+			Hint:   "Test eof before reading.",
+			Pos:    arg.Pos(),
+			End:    arg.End(),
+		}
+	}
+	if err != nil {
+		return nil, ioError(fmt.Sprintf("Cannot read from %s", f.name()), err, arg)
+	}
+	if b == '\r' {
+		f.readByte()
+		if b, err := f.peekByte(); err == nil && b == '\n' {
+			f.readByte()
+		}
+		return char(' '), nil
+	}
+	if f.readByte() == '\n' {
+		return char(' '), nil
+	}
+	return char(b), nil
+}
+
+// readString reads the rest of the current line of the text file f, but
+// not the line end, as Turbo Pascal does. At the end of a line it reads the
+// empty string.
+func readString(f *file, arg parser.Expr) (Value, error) {
+	var text []byte
+	for {
+		b, err := f.peekByte()
+		if err == io.EOF || (err == nil && (b == '\n' || b == '\r')) {
+			return string(text), nil
+		}
+		if err != nil {
+			return nil, ioError(fmt.Sprintf("Cannot read from %s", f.name()), err, arg)
+		}
+		text = append(text, f.readByte())
+	}
+}
+
+// readNumber reads an integer, or a real if real is set, from the text file
+// f: optional blanks and line ends, an optional sign, and one or more
+// digits. A real may go on with a fraction such as '.25' and an exponent
+// such as 'e-3', each of which must have digits.
+func readNumber(f *file, real bool, arg parser.Expr) (Value, error) {
+	kind := "integer"
+	if real {
+		kind = "real"
+	}
+
+	for {
+		b, err := f.peekByte()
+		if err == io.EOF {
+			return nil, &PascalError{
+				Msg:    "Read past end of file",
+				Detail: fmt.Sprintf("There is no %s left to read in %s.", kind, f.name()),
+				Hint:   "Test eof before reading.",
+				Pos:    arg.Pos(),
+				End:    arg.End(),
+			}
+		}
+		if err != nil {
+			return nil, ioError(fmt.Sprintf("Cannot read from %s", f.name()), err, arg)
+		}
+		if b != ' ' && b != '\t' && b != '\n' && b != '\r' {
+			break
+		}
+		f.readByte()
+	}
+
+	line := f.line
+	var text []byte
+	// accept consumes the next character if it is one of chars.
+	accept := func(chars string) bool {
+		b, err := f.peekByte()
+		if err != nil || !strings.ContainsRune(chars, rune(b)) {
+			return false
+		}
+		text = append(text, f.readByte())
+		return true
+	}
+	// acceptDigits consumes a run of digits, and reports whether there were
+	// any.
+	acceptDigits := func() bool {
+		n := 0
+		for accept("0123456789") {
+			n++
+		}
+		return n > 0
+	}
+	accept("+-")
+	wellFormed := acceptDigits()
+	if real {
+		// The fraction and the exponent each need digits: ISO 6.1.5 has
+		// no '.5' or '1.'.
+		if accept(".") && !acceptDigits() {
+			wellFormed = false
+		}
+		if accept("eE") {
+			accept("+-")
+			if !acceptDigits() {
+				wellFormed = false
+			}
+		}
+	}
+
+	var val Value
+	var err error
+	if !wellFormed {
+		err = strconv.ErrSyntax
+	} else if real {
+		val, err = strconv.ParseFloat(string(text), 64)
+	} else {
+		var n int
+		n, err = strconv.Atoi(string(text))
+		if n == math.MinInt {
+			// -maxint-1 fits in an int, but not in the range of integer.
+			err = strconv.ErrRange
+		}
+		val = n
+	}
+	if err != nil {
+		found := string(text)
+		if len(text) == 0 || text[len(text)-1] < '0' || text[len(text)-1] > '9' {
+			found += f.peekWord()
+		}
+		detail := fmt.Sprintf("Expected %s on line %d of %s, but found %q.", article(kind), line, f.name(), found)
+		if errors.Is(err, strconv.ErrRange) {
+			detail = fmt.Sprintf("The number %s on line %d of %s is too large for %s.", found, line, f.name(), article(kind))
+		}
+		return nil, &PascalError{
+			Msg:    fmt.Sprintf("Malformed %s in input", kind),
+			Detail: detail,
+			Hint:   "Check the input data, and that the values are read in the order they were written.",
+			Pos:    arg.Pos(),
+			End:    arg.End(),
+		}
+	}
+	return val, nil
+}
+
+// article returns noun preceded by "a" or "an".
+func article(noun string) string {
+	if strings.ContainsRune("aeiou", rune(noun[0])) {
+		return "an " + noun
+	}
+	return "a " + noun
+}
+
+// skipLine skips the rest of the current line of the text file f,
+// including the line end.
+func skipLine(f *file, at parser.Node) error {
+	for {
+		_, err := f.peekByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return ioError(fmt.Sprintf("Cannot read from %s", f.name()), err, at)
+		}
+		if f.readByte() == '\n' {
+			return nil
+		}
+	}
+}
+
+func (f *file) peekByte() (byte, error) {
+	b, err := f.r.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// readByte consumes the next character of the text file f, which the
+// caller has checked with peekByte.
+func (f *file) readByte() byte {
+	b, _ := f.r.ReadByte()
+	if b == '\n' {
+		f.line++
+	}
+	return b
+}
+
+// peekWord returns the characters up to the next blank or line end,
+// without consuming them, to show in a message.
+func (f *file) peekWord() string {
+	buf, _ := f.r.Peek(16)
+	for i, b := range buf {
+		if b == ' ' || b == '\t' || b == '\n' || b == '\r' {
+			return string(buf[:i])
+		}
+	}
+	return string(buf)
+}
+
+// readComponent reads the next component of the binary file f, for the
+// variable access arg of type t.
+func readComponent(f *file, t parser.Type, arg parser.Expr) (Value, error) {
+	if _, err := f.r.Peek(1); err == io.EOF {
+		return nil, &PascalError{
+			Msg:    "Read past end of file",
+			Detail: fmt.Sprintf("There are no components left to read in %s.", f.name()),
+			Hint:   "Test eof before reading.",
+			Pos:    arg.Pos(),
+			End:    arg.End(),
+		}
+	}
+	val, err := decodeValue(f.r, f.typ.Elem)
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, errCorrupt) {
+			return nil, &PascalError{
+				Msg:    "Corrupt file",
+				Detail: fmt.Sprintf("%s does not hold components of type %s.", capitalize(f.name()), f.typ.Elem),
+				Hint:   "Read the file with the same 'file of' type it was written with.",
+				Pos:    arg.Pos(),
+				End:    arg.End(),
+			}
+		}
+		return nil, ioError(fmt.Sprintf("Cannot read from %s", f.name()), err, arg)
+	}
+	if !compatible(t, val) {
+		return nil, &PascalError{
+			Msg:    "Type mismatch in read",
+			Detail: fmt.Sprintf("The file holds %s values, but %s is of type %s.", f.typ.Elem, describe(arg), t),
+			Hint:   "Read into a variable of the file's component type.",
+			Pos:    arg.Pos(),
+			End:    arg.End(),
+		}
+	}
+	return val, nil
+}
+
+var (
+	errUndefined = errors.New("undefined value")
+	errCorrupt   = errors.New("corrupt component")
+)
+
+// maxStringComponent bounds the length of a string read from a binary
+// file, so that a corrupt length is reported rather than exhausting memory.
+const maxStringComponent = 1 << 24
+
+// encodeValue writes v to w in the binary format of file components:
+// ordinal numbers as 64-bit little-endian integers, reals as 64-bit IEEE
+// 754 numbers, strings as their length followed by their characters, and
+// structured values as their components in order.
+func encodeValue(w io.Writer, v Value) error {
+	switch v := v.(type) {
+	case array:
+		for _, elem := range v.elems {
+			if err := encodeValue(w, elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	case record:
+		for i, part := range v.typ.Parts {
+			active := int64(-1)
+			for j, variant := range part.Variants {
+				if v.active[i] == variant {
+					active = int64(j)
+				}
+			}
+			if err := binary.Write(w, binary.LittleEndian, active); err != nil {
+				return err
+			}
+		}
+		for _, field := range v.fields {
+			if err := encodeValue(w, field); err != nil {
+				return err
+			}
+		}
+		return nil
+	case set:
+		return binary.Write(w, binary.LittleEndian, v.bits)
+	case float64:
+		return binary.Write(w, binary.LittleEndian, v)
+	case string:
+		if err := binary.Write(w, binary.LittleEndian, int64(len(v))); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, v)
+		return err
+	case undefined:
+		return errUndefined
+	}
+	n, _ := ordinal(v)
+	return binary.Write(w, binary.LittleEndian, int64(n))
+}
+
+// decodeValue reads a value of type t written by encodeValue.
+func decodeValue(r io.Reader, t parser.Type) (Value, error) {
+	switch t := t.(type) {
+	case *parser.ArrayType:
+		a := zeroValue(t).(array)
+		for i := range a.elems {
+			elem, err := decodeValue(r, t.Elem)
+			if err != nil {
+				return nil, err
+			}
+			a.elems[i] = elem
+		}
+		return a, nil
+	case *parser.RecordType:
+		rec := zeroValue(t).(record)
+		for i, part := range t.Parts {
+			var active int64
+			if err := binary.Read(r, binary.LittleEndian, &active); err != nil {
+				return nil, err
+			}
+			if active < -1 || active >= int64(len(part.Variants)) {
+				return nil, errCorrupt
+			}
+			rec.active[i] = nil
+			if active >= 0 {
+				rec.active[i] = part.Variants[active]
+			}
+		}
+		for i, f := range t.Fields {
+			field, err := decodeValue(r, f.Type)
+			if err != nil {
+				return nil, err
+			}
+			rec.fields[i] = field
+		}
+		return rec, nil
+	case *parser.SetType:
+		s := set{typ: t}
+		if err := binary.Read(r, binary.LittleEndian, &s.bits); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	if t == parser.Real {
+		var x float64
+		err := binary.Read(r, binary.LittleEndian, &x)
+		return x, err
+	}
+	if t == parser.String {
+		var n int64
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		if n < 0 || n > maxStringComponent {
+			return nil, errCorrupt
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+
+	var n int64
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	if t != parser.Integer {
+		if low, high := parser.Bounds(t); n < int64(low) || n > int64(high) {
+			return nil, errCorrupt
+		}
+	}
+	return fromOrdinal(t, int(n)), nil
+}
+
+// stmtName returns the name of a read or write statement, with "ln"
+// appended if newline is set.
+func stmtName(newline bool, name string) string {
+	if newline {
+		return name + "ln"
+	}
+	return name
+}
+
+func modeError(f *file, mode fileMode, open string, at parser.Node) error {
+	detail := fmt.Sprintf("%s is not open.", capitalize(f.name()))
+	if f.mode != closed {
+		detail = fmt.Sprintf("%s is open for %s.", capitalize(f.name()), f.mode)
+	}
+	if f.path == "" && f.std == "" {
+		detail = "The file has not been assigned a name or opened."
+	}
+	return &PascalError{
+		Msg:    fmt.Sprintf("File not open for %s", mode),
+		Detail: detail,
+		Hint:   fmt.Sprintf("Call %s on the file before using it for %s.", open, mode),
+		Pos:    at.Pos(),
+		End:    at.End(),
+	}
+}
+
+func textOnlyError(name string, f *file, at parser.Node) error {
+	return &PascalError{
+		Msg:    fmt.Sprintf("'%s' needs a text file", name),
+		Detail: fmt.Sprintf("%s is a %s, which has components rather than lines.", capitalize(f.name()), f.typ),
+		Hint:   "Use read and write with files of other types than text.",
+		Pos:    at.Pos(),
+		End:    at.End(),
+	}
+}
+
+func writeError(f *file, err error, at parser.Node) error {
+	return ioError(fmt.Sprintf("Cannot write to %s", f.name()), err, at)
+}
+
+// ioError reports the failure err of an operation on a file.
+func ioError(msg string, err error, at parser.Node) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return &PascalError{
+		Msg:    msg,
+		Detail: fmt.Sprintf("The system reported: %s.", err),
+		Hint:   "Check that the path is right, relative to the directory the program is run in, and that you may access it.",
+		Pos:    at.Pos(),
+		End:    at.End(),
+	}
+}
@@ -0,0 +1,183 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"pastel/parser"
+	"strconv"
+	"strings"
+)
+
+// realWidth is the field width of a real written without one, and
+// expDigits the number of digits in the exponent of a real written in
+// floating-point form. ISO 7185 leaves both to the implementation; these
+// match Free Pascal, e.g. ' 3.140000000000000E+000'.
+const (
+	realWidth = 23
+	expDigits = 3
+)
+
+// formatArg evaluates the argument of write or writeln and returns the text
+// it writes: the value, padded on the left with spaces to the field width.
+// Without a width, integers and booleans take the dialect's default width,
+// reals realWidth, and other values as many characters as they need. A real
+// is written in floating-point form, or with Places digits after the
+// decimal point if they are given.
+func formatArg(arg *parser.WriteArg, env *Environment) (string, error) {
+	val, err := EvalExpr(arg.Value, env)
+	if err != nil {
+		return "", err
+	}
+
+	if x, ok := val.(float64); ok {
+		return formatRealArg(arg, x, env)
+	}
+
+	var width int
+	switch val.(type) {
+	case int:
+		width = env.dialect.IntWidth
+	case bool:
+		width = env.dialect.BoolWidth
+	case char, enum, string:
+	default:
+		if !isString(val) {
+			return "", &PascalError{
+				Msg:    fmt.Sprintf("Cannot write a value of type %s", typeName(val)),
+				Detail: "Only integers, reals, booleans, characters, enumerated values and strings can be written as text.",
+				Hint:   "Write the components of the value one at a time.",
+				Pos:    arg.Value.Pos(),
+				End:    arg.Value.End(),
+			}
+		}
+	}
+
+	if arg.Places != nil {
+		return "", &PascalError{
+			Msg:    "Decimal places given for a value that is not real",
+			Detail: fmt.Sprintf("The value written is %s, which has no decimal places.", typeName(val)),
+			Hint:   "Give only a field width, e.g. 'x:8'.",
+			Pos:    arg.Places.Pos(),
+			End:    arg.Places.End(),
+		}
+	}
+
+	text, ok := asString(val)
+	if !ok {
+		text = formatValue(val)
+	}
+	if arg.Width != nil {
+		if width, err = evalWidth(arg.Width, "field width", env); err != nil {
+			return "", err
+		}
+		// ISO writes only the first width characters of a longer string,
+		// and writes a boolean as though it were the string of its name.
+		_, isBool := val.(bool)
+		if (isString(val) || isBool) && env.dialect.TruncateStrings && width < len(text) {
+			text = text[:width]
+		}
+	}
+	return pad(text, width), nil
+}
+
+// formatRealArg returns the text write writes for the real argument x.
+func formatRealArg(arg *parser.WriteArg, x float64, env *Environment) (string, error) {
+	width := realWidth
+	if arg.Width != nil {
+		var err error
+		if width, err = evalWidth(arg.Width, "field width", env); err != nil {
+			return "", err
+		}
+	}
+	if arg.Places == nil {
+		return pad(formatFloat(x, width), width), nil
+	}
+	places, err := evalWidth(arg.Places, "number of decimal places", env)
+	if err != nil {
+		return "", err
+	}
+	return pad(strconv.FormatFloat(x, 'f', places, 64), width), nil
+}
+
+// formatFloat returns x in the floating-point form of ISO 7185: a sign,
+// which is a space for a positive number, one digit, a decimal point, as
+// many digits as fit in width, and an exponent of expDigits digits, e.g.
+// ' 1.50E+002' for width 10. The result is at least expDigits+6 characters
+// wide, to leave room for one digit after the point.
+func formatFloat(x float64, width int) string {
+	width = max(width, expDigits+6)
+	digits := width - expDigits - 5
+
+	mantissa, exp, _ := strings.Cut(strconv.FormatFloat(math.Abs(x), 'e', digits, 64), "e")
+	n, _ := strconv.Atoi(exp)
+	expSign := "+"
+	if n < 0 {
+		expSign, n = "-", -n
+	}
+	sign := " "
+	if x < 0 {
+		sign = "-"
+	}
+	return fmt.Sprintf("%s%sE%s%0*d", sign, mantissa, expSign, expDigits, n)
+}
+
+// maxFieldWidth bounds field widths and numbers of decimal places, so that
+// a mistaken width is reported rather than exhausting memory.
+const maxFieldWidth = 1 << 12
+
+// evalWidth evaluates expr, the field width or number of decimal places
+// named by what, which must be an integer in 1..maxFieldWidth, or
+// 0..maxFieldWidth if the dialect allows a width of 0.
+func evalWidth(expr parser.Expr, what string, env *Environment) (int, error) {
+	val, err := EvalExpr(expr, env)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := val.(int)
+	if !ok {
+		return 0, &PascalError{
+			Msg:    capitalize(what) + " must be an integer",
+			Detail: fmt.Sprintf("The %s is %s.", what, typeName(val)),
+			Hint:   "Give the number of characters to write, e.g. 'x:8'.",
+			Pos:    expr.Pos(),
+			End:    expr.End(),
+		}
+	}
+	if n < 0 {
+		return 0, &PascalError{
+			Msg:    "Negative " + what,
+			Detail: fmt.Sprintf("The %s is %d.", what, n),
+			Hint:   "Check the value before using it as a width.",
+			Pos:    expr.Pos(),
+			End:    expr.End(),
+		}
+	}
+	if n == 0 && !env.dialect.ZeroWidth {
+		return 0, &PascalError{
+			Msg:    "Zero " + what,
+			Detail: fmt.Sprintf("The %s is 0, but the %s dialect requires at least 1.", what, env.dialect.Name),
+			Hint:   "Give a value of at least 1, or use the turbo or fpc dialect, which allow 0.",
+			Pos:    expr.Pos(),
+			End:    expr.End(),
+		}
+	}
+	if n > maxFieldWidth {
+		return 0, &PascalError{
+			Msg:    capitalize(what) + " too large",
+			Detail: fmt.Sprintf("The %s is %d, but it can be at most %d.", what, n, maxFieldWidth),
+			Hint:   "Check the value before using it as a width.",
+			Pos:    expr.Pos(),
+			End:    expr.End(),
+		}
+	}
+	return n, nil
+}
+
+// pad returns text right-aligned in a field of width characters. Text that
+// is longer than the field is returned whole.
+func pad(text string, width int) string {
+	if len(text) >= width {
+		return text
+	}
+	return strings.Repeat(" ", width-len(text)) + text
+}
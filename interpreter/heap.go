@@ -0,0 +1,214 @@
+package interpreter
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"pastel/diagnostics"
+	"pastel/parser"
+	"pastel/token"
+	"slices"
+)
+
+// pointer is a value of a pointer type. It points to a cell on the heap,
+// or to nothing when cell is nil. The value of 'nil' itself has no type
+// until it is stored.
+type pointer struct {
+	typ  *parser.PointerType
+	cell *cell
+}
+
+// cell is a variable created by new. After dispose, disposed records where
+// it was disposed, and the cell must no longer be accessed. holders holds
+// the with statements and var parameters that refer to the cell, which
+// must not be disposed until they end.
+type cell struct {
+	id        int // allocation order, for reporting leaks
+	value     Value
+	typ       parser.Type
+	allocated parser.Node
+	disposed  parser.Node
+	holders   []parser.Node
+}
+
+// hold records that the variable access at refers to c.
+func (c *cell) hold(at parser.Node) {
+	c.holders = append(c.holders, at)
+}
+
+// release ends the most recent hold on c.
+func (c *cell) release() {
+	c.holders = c.holders[:len(c.holders)-1]
+}
+
+// heapCell returns the heap variable that r refers to or is part of, or
+// nil if r refers to a variable that was not created by new.
+func heapCell(r ref) *cell {
+	switch r := r.(type) {
+	case derefRef:
+		return r.cell
+	case elemRef:
+		return r.cell
+	case fieldRef:
+		return r.cell
+	default:
+		return nil
+	}
+}
+
+// heap holds the cells that have been allocated and not yet disposed. All
+// the environments of a program share one heap.
+type heap struct {
+	allocs int
+	live   map[*cell]bool
+}
+
+func newHeap() *heap {
+	return &heap{live: make(map[*cell]bool)}
+}
+
+// derefRef refers to the heap variable a pointer points to.
+type derefRef struct {
+	cell *cell
+}
+
+func (r derefRef) get() Value       { return r.cell.value }
+func (r derefRef) set(v Value)      { r.cell.value = overwrite(r.cell.value, v) }
+func (r derefRef) typ() parser.Type { return r.cell.typ }
+
+// evalDeref evaluates the pointer in e and returns a reference to the heap
+// variable it points to, reporting an error if it is nil or disposed.
+func evalDeref(e *parser.DerefExpr, env *Environment) (ref, error) {
+	base, err := evalRef(e.Pointer, env)
+	if err != nil {
+		return nil, err
+	}
+	val, err := readRef(base, e.Pointer)
+	if err != nil {
+		return nil, err
+	}
+	ptr, ok := val.(pointer)
+	if !ok {
+		return nil, &PascalError{
+			Msg:    fmt.Sprintf("%s is not a pointer", capitalize(describe(e.Pointer))),
+			Detail: fmt.Sprintf("Only pointers can be followed with '^', but this is %s.", typeName(val)),
+			Hint:   "Remove the '^', or apply it to a pointer variable.",
+			Pos:    e.Pointer.Pos(),
+			End:    e.Pointer.End(),
+		}
+	}
+	if err := checkLive(ptr, e.Pointer, "Dereference"); err != nil {
+		return nil, err
+	}
+	return derefRef{cell: ptr.cell}, nil
+}
+
+// checkLive reports an error if ptr, the value of expr, is nil or points
+// to a cell that has been disposed. op names the operation for the message.
+func checkLive(ptr pointer, expr parser.Expr, op string) error {
+	if ptr.cell == nil {
+		return &PascalError{
+			Msg:    op + " of nil pointer",
+			Detail: fmt.Sprintf("%s is nil, so it does not point to a variable.", capitalize(describe(expr))),
+			Hint:   "Check the pointer against nil first, or allocate a variable for it with new.",
+			Pos:    expr.Pos(),
+			End:    expr.End(),
+		}
+	}
+	if d := ptr.cell.disposed; d != nil {
+		return &PascalError{
+			Msg:    op + " of disposed pointer",
+			Detail: fmt.Sprintf("The variable %s points to has already been disposed.", describe(expr)),
+			Hint:   "Do not use a pointer after passing it, or a copy of it, to dispose.",
+			Pos:    expr.Pos(),
+			End:    expr.End(),
+			Labels: []diagnostics.Label{
+				{Pos: ptr.cell.allocated.Pos(), End: ptr.cell.allocated.End(), Msg: "allocated here"},
+				{Pos: d.Pos(), End: d.End(), Msg: "disposed here"},
+			},
+		}
+	}
+	return nil
+}
+
+// builtinNew allocates a variable of the domain type of the pointer
+// variable args[0], and points it there.
+func builtinNew(args []Value, call parser.Node, env *Environment) (Value, error) {
+	target := args[0].(ref)
+	typ, ok := target.typ().(*parser.PointerType)
+	if !ok {
+		return nil, pointerArgError("new", target, call)
+	}
+
+	env.heap.allocs++
+	c := &cell{id: env.heap.allocs, value: zeroValue(typ.Target), typ: typ.Target, allocated: call}
+	env.heap.live[c] = true
+	target.set(pointer{typ: typ, cell: c})
+	return nil, nil
+}
+
+// builtinDispose frees the variable the pointer variable args[0] points to.
+func builtinDispose(args []Value, call parser.Node, env *Environment) (Value, error) {
+	target := args[0].(ref)
+	ptr, ok := target.get().(pointer)
+	if !ok {
+		return nil, pointerArgError("dispose", target, call)
+	}
+	arg := call.(*parser.CallStmt).Args[0]
+	if err := checkLive(ptr, arg, "Dispose"); err != nil {
+		return nil, err
+	}
+	if n := len(ptr.cell.holders); n > 0 {
+		held := ptr.cell.holders[n-1]
+		return nil, &PascalError{
+			Msg:    "Dispose of a variable in use",
+			Detail: fmt.Sprintf("The variable %s points to is in use by a with statement or a var parameter, which would be left referring to freed memory.", describe(arg)),
+			Hint:   "Dispose of the variable after the with statement or the call has finished.",
+			Pos:    call.Pos(),
+			End:    call.End(),
+			Labels: []diagnostics.Label{{Pos: held.Pos(), End: held.End(), Msg: "in use here"}},
+		}
+	}
+
+	ptr.cell.disposed = call
+	delete(env.heap.live, ptr.cell)
+	return nil, nil
+}
+
+func pointerArgError(name string, target ref, call parser.Node) error {
+	return &PascalError{
+		Msg:    fmt.Sprintf("Argument of '%s' must be a pointer variable", name),
+		Detail: fmt.Sprintf("The argument is of type %s.", target.typ()),
+		Hint:   fmt.Sprintf("Pass a variable of a pointer type, e.g. '%s(p)' where p is '^node'.", name),
+		Pos:    call.Pos(),
+		End:    call.End(),
+	}
+}
+
+// Leak is a variable allocated with new that was never disposed.
+type Leak struct {
+	Type string
+	Pos  token.Position // the call of new that allocated it
+	End  token.Position
+}
+
+// Diagnostic describes the leak for the diagnostics renderer.
+func (l Leak) Diagnostic() *diagnostics.Diagnostic {
+	return &diagnostics.Diagnostic{
+		Kind:    "Memory Leak",
+		Msg:     fmt.Sprintf("A variable of type %s allocated here was never disposed", l.Type),
+		Hint:    "Pass every pointer you get from new to dispose once you are done with it.",
+		Primary: diagnostics.Label{Pos: l.Pos, End: l.End},
+	}
+}
+
+// Leaks returns the variables allocated with new that have not been
+// disposed, in the order they were allocated.
+func (e *Environment) Leaks() []Leak {
+	cells := slices.SortedFunc(maps.Keys(e.heap.live), func(a, b *cell) int { return cmp.Compare(a.id, b.id) })
+	leaks := make([]Leak, len(cells))
+	for i, c := range cells {
+		leaks[i] = Leak{Type: c.typ.String(), Pos: c.allocated.Pos(), End: c.allocated.End()}
+	}
+	return leaks
+}
@@ -2,125 +2,998 @@ package interpreter
 
 import (
 	"fmt"
+	"math"
+	"pastel/diagnostics"
 	"pastel/parser"
 	"pastel/token"
 )
 
 // EvalProgram evaluates the entire Pascal program.
 func EvalProgram(prog *parser.Program, env *Environment) error {
-	for _, decl := range prog.Declarations {
-		if v, ok := decl.(*parser.VarDecl); ok {
-			env.Set(v.Name, 0) // default value is 0
-		}
-	}
+	evalDeclarations(prog.Declarations, env)
 
-	if err := EvalStmt(prog.Main, env); err != nil {
-		return err
+	err := EvalStmt(prog.Main, env)
+
+	// Write out what the program wrote to files it did not close, even if
+	// it stopped with an error.
+	if cerr := env.closeFiles(prog); err == nil {
+		err = cerr
 	}
+	return err
+}
 
-	return nil
+// evalDeclarations binds the constants, variables and routines declared by
+// a block.
+func evalDeclarations(decls []parser.Stmt, env *Environment) {
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *parser.ConstDecl:
+			env.defineConst(d)
+		case *parser.VarDecl:
+			env.declareVar(d.Name, d.Type, zeroValue(d.Type))
+		case *parser.RoutineDecl:
+			if !d.Forward {
+				env.Define(d.Name, routine{decl: d, env: env})
+			}
+		}
+	}
 }
 
 // EvalStmt evaluates a single statement.
 func EvalStmt(stmt parser.Stmt, env *Environment) error {
 	switch s := stmt.(type) {
+	case nil:
+		// The empty statement, e.g. a missing else branch.
+
 	case *parser.AssignStmt:
-		if !env.Exists(s.Name) {
-			return &PascalError{
-				Msg:    fmt.Sprintf("Undeclared variable '%s'", s.Name),
-				Detail: "This variable is being used but was never declared with a type.",
-				Hint:   fmt.Sprintf("Try adding `var %s: integer;` at the top of your program.", s.Name),
+		return evalAssign(s, env)
+
+	case *parser.CompoundStmt:
+		for _, stmt := range s.Statements {
+			if err := EvalStmt(stmt, env); err != nil {
+				return err
 			}
 		}
 
-		val, err := EvalExpr(s.Value, env)
+	case *parser.IfStmt:
+		cond, err := evalCondition(s.Condition, "if", env)
 		if err != nil {
 			return err
 		}
-		env.Set(s.Name, val)
+		if cond {
+			return EvalStmt(s.Then, env)
+		}
+		return EvalStmt(s.Else, env)
 
-	case *parser.CompoundStmt:
-		for _, stmt := range s.Statements {
-			if err := EvalStmt(stmt, env); err != nil {
+	case *parser.WhileStmt:
+		for {
+			cond, err := evalCondition(s.Condition, "while", env)
+			if err != nil {
+				return err
+			}
+			if !cond {
+				break
+			}
+			if err := EvalStmt(s.Body, env); err != nil {
 				return err
 			}
 		}
 
-	case *parser.PrintStmt:
-		val, err := EvalExpr(s.Argument, env)
+	case *parser.RepeatStmt:
+		for {
+			for _, stmt := range s.Body {
+				if err := EvalStmt(stmt, env); err != nil {
+					return err
+				}
+			}
+			cond, err := evalCondition(s.Condition, "until", env)
+			if err != nil {
+				return err
+			}
+			if cond {
+				break
+			}
+		}
+
+	case *parser.ForStmt:
+		return evalFor(s, env)
+
+	case *parser.CaseStmt:
+		return evalCase(s, env)
+
+	case *parser.WithStmt:
+		return evalWith(s, env)
+
+	case *parser.CallStmt:
+		if val, ok := env.Get(s.Name); ok {
+			if b, ok := val.(builtin); ok {
+				if b.IsFunction {
+					return functionStmtError(s, b.Name, nil)
+				}
+				_, err := callBuiltin(b, s.Args, s, env)
+				return err
+			}
+		}
+		r, err := lookupRoutine(s.Name, s, env)
 		if err != nil {
 			return err
 		}
-		fmt.Println(val)
+		if r.decl.IsFunction {
+			return functionStmtError(s, s.Name, declaredHere(r.decl))
+		}
+		_, err = callRoutine(r, s.Args, s, env)
+		return err
+
+	case *parser.PrintStmt:
+		return evalWrite(s, env)
+
+	case *parser.ReadStmt:
+		return evalRead(s, env)
 
 	default:
 		return &PascalError{
 			Msg:    "Unknown statement type",
 			Detail: fmt.Sprintf("Encountered an unsupported statement: %T", stmt),
 			Hint:   "Ensure all statements are valid Pascal constructs.",
+			Pos:    nodePos(stmt),
+			End:    nodeEnd(stmt),
 		}
 	}
 
 	return nil
 }
 
-// EvalExpr evaluates an expression and returns its integer value.
-func EvalExpr(expr parser.Expr, env *Environment) (int, error) {
+// EvalExpr evaluates an expression and returns its value.
+func EvalExpr(expr parser.Expr, env *Environment) (Value, error) {
 	switch e := expr.(type) {
 	case *parser.IntegerLiteral:
 		return e.Value, nil
 
-	case *parser.BinaryExpr:
-		left, err := EvalExpr(e.Left, env)
+	case *parser.RealLiteral:
+		return e.Value, nil
+
+	case *parser.BooleanLiteral:
+		return e.Value, nil
+
+	case *parser.StringLiteral:
+		return stringValue(e.Value), nil
+
+	case *parser.EnumLiteral:
+		return enum{typ: e.Type, ord: e.Ord}, nil
+
+	case *parser.UnaryExpr:
+		return evalUnary(e, env)
+
+	case *parser.CallExpr:
+		if val, ok := env.Get(e.Name); ok {
+			if b, ok := val.(builtin); ok {
+				return callBuiltinFunction(b, e.Args, e, env)
+			}
+		}
+		r, err := lookupRoutine(e.Name, e, env)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
+		return callFunction(r, e.Args, e, env)
+
+	case *parser.BinaryExpr:
+		return evalBinary(e, env)
+
+	case *parser.NilLiteral:
+		return pointer{}, nil
+
+	case *parser.SetConstructor:
+		return evalSetConstructor(e, env)
 
-		right, err := EvalExpr(e.Right, env)
+	case *parser.IndexExpr, *parser.FieldExpr, *parser.DerefExpr:
+		target, err := evalRef(e, env)
 		if err != nil {
-			return 0, err
-		}
-
-		switch e.Operator.Type {
-		case token.PLUS:
-			return left + right, nil
-		case token.MINUS:
-			return left - right, nil
-		case token.STAR:
-			return left * right, nil
-		case token.SLASH:
-			if right == 0 {
-				return 0, &PascalError{
-					Msg:    "Division by zero",
-					Detail: "An attempt was made to divide by zero.",
-					Hint:   "Ensure the divisor is not zero before performing division.",
-				}
-			}
-			return left / right, nil
-		default:
-			return 0, &PascalError{
-				Msg:    "Unknown operator",
-				Detail: fmt.Sprintf("Operator '%s' is not supported.", e.Operator.Literal),
-				Hint:   "Use valid operators such as +, -, *, or /.",
-			}
+			return nil, err
 		}
+		return readRef(target, e)
 
 	case *parser.Identifier:
 		val, ok := env.Get(e.Value)
 		if !ok {
-			return 0, &PascalError{
+			return nil, &PascalError{
 				Msg:    fmt.Sprintf("Undefined variable '%s'", e.Value),
 				Detail: "This variable is being used but was never declared or assigned a value.",
 				Hint:   fmt.Sprintf("Declare the variable using `var %s: integer;` and assign it a value before use.", e.Value),
+				Pos:    e.Pos(),
+				End:    e.End(),
+			}
+		}
+		switch v := val.(type) {
+		case routine:
+			return callFunction(v, nil, e, env)
+		case builtin:
+			return callBuiltinFunction(v, nil, e, env)
+		}
+		if u, ok := val.(undefined); ok {
+			return nil, &PascalError{
+				Msg:    fmt.Sprintf("Variable '%s' is undefined", e.Value),
+				Detail: u.reason,
+				Hint:   fmt.Sprintf("Assign a value to '%s' before reading it.", e.Value),
+				Pos:    e.Pos(),
+				End:    e.End(),
 			}
 		}
 		return val, nil
 
 	default:
-		return 0, &PascalError{
+		return nil, &PascalError{
 			Msg:    "Unknown expression type",
 			Detail: fmt.Sprintf("Encountered an unsupported expression: %T", expr),
 			Hint:   "Ensure all expressions are valid Pascal constructs.",
+			Pos:    nodePos(expr),
+			End:    nodeEnd(expr),
 		}
 	}
 }
+
+// evalAssign assigns the value of s.Value to the variable s.Target, or to
+// the result of the function that s.Target names.
+func evalAssign(s *parser.AssignStmt, env *Environment) error {
+	if ident, ok := s.Target.(*parser.Identifier); ok {
+		current, ok := env.Get(ident.Value)
+		if !ok {
+			return &PascalError{
+				Msg:    fmt.Sprintf("Undeclared variable '%s'", ident.Value),
+				Detail: "This variable is being used but was never declared with a type.",
+				Hint:   fmt.Sprintf("Try adding `var %s: integer;` at the top of your program.", ident.Value),
+				Pos:    s.Pos(),
+				End:    s.End(),
+			}
+		}
+
+		if decl := env.constant(ident.Value); decl != nil {
+			return constantError(decl, s)
+		}
+
+		if r, ok := current.(routine); ok {
+			val, err := EvalExpr(s.Value, env)
+			if err != nil {
+				return err
+			}
+			return assignResult(s, r, val, env)
+		}
+	}
+
+	// The value is evaluated first, as evaluating it can call a function
+	// that changes the variable, e.g. shortens the string a character of
+	// which is assigned.
+	val, err := EvalExpr(s.Value, env)
+	if err != nil {
+		return err
+	}
+
+	target, err := evalRef(s.Target, env)
+	if err != nil {
+		return err
+	}
+
+	typ := target.typ()
+	if parser.IsFile(typ) {
+		return &PascalError{
+			Msg:    "Files cannot be assigned",
+			Detail: fmt.Sprintf("%s is of type %s, which is or contains a file.", capitalize(describe(s.Target)), typ),
+			Hint:   "Copy the contents of a file by reading from one and writing to the other.",
+			Pos:    s.Pos(),
+			End:    s.End(),
+		}
+	}
+	if !compatible(typ, val) {
+		return &PascalError{
+			Msg:    "Type mismatch in assignment",
+			Detail: fmt.Sprintf("Cannot assign a %s value to %s, which is of type %s.", typeName(val), describe(s.Target), typ),
+			Hint:   "The value assigned must have the same type as the variable.",
+			Pos:    s.Value.Pos(),
+			End:    s.Value.End(),
+		}
+	}
+	if err := checkRange(typ, val, s.Value, describe(s.Target)); err != nil {
+		return err
+	}
+	if err := checkVariant(target, s.Target); err != nil {
+		return err
+	}
+	target.set(storedValue(typ, val))
+	return nil
+}
+
+// evalFor runs a for loop with ISO semantics: both bounds are evaluated once
+// before the loop starts, the control variable cannot be assigned by the
+// body, and it is left undefined when the loop finishes.
+func evalFor(s *parser.ForStmt, env *Environment) error {
+	name := s.Variable.Value
+
+	if !env.Exists(name) {
+		return &PascalError{
+			Msg:    fmt.Sprintf("Undeclared variable '%s'", name),
+			Detail: "The control variable of a for loop must be declared like any other variable.",
+			Hint:   fmt.Sprintf("Try adding `var %s: integer;` at the top of your program.", name),
+			Pos:    s.Variable.Pos(),
+			End:    s.Variable.End(),
+		}
+	}
+	if loop := env.controlledBy(name); loop != nil {
+		return controlVariableError(name, s.Variable, loop)
+	}
+	typ := env.varType(name)
+	if typ == nil || !parser.IsOrdinal(typ) {
+		current, _ := env.Get(name)
+		return &PascalError{
+			Msg:    fmt.Sprintf("Control variable '%s' must be of an ordinal type", name),
+			Detail: fmt.Sprintf("'%s' is a %s.", name, typeName(current)),
+			Hint:   "Use an integer or enumerated variable to count the loop.",
+			Pos:    s.Variable.Pos(),
+			End:    s.Variable.End(),
+		}
+	}
+
+	bounds := make([]int, 2)
+	for i, expr := range []parser.Expr{s.Initial, s.Final} {
+		val, err := EvalExpr(expr, env)
+		if err != nil {
+			return err
+		}
+		n, ok := ordinal(val)
+		if !ok || !compatible(typ, val) {
+			return &PascalError{
+				Msg:    "Type mismatch in for loop bound",
+				Detail: fmt.Sprintf("The bound is %s, but the control variable '%s' is %s.", typeName(val), name, typ),
+				Hint:   "Both bounds of a for loop must have the same type as its control variable.",
+				Pos:    expr.Pos(),
+				End:    expr.End(),
+			}
+		}
+		bounds[i] = n
+	}
+	first, last := bounds[0], bounds[1]
+
+	step := 1
+	if s.Downto {
+		step = -1
+	}
+
+	env.lockControl(name, s)
+	defer env.unlockControl(name)
+
+	if (!s.Downto && first <= last) || (s.Downto && first >= last) {
+		// Every value in between lies within the bounds, so checking them
+		// checks the whole loop.
+		for i, expr := range []parser.Expr{s.Initial, s.Final} {
+			if err := checkRange(typ, fromOrdinal(typ, bounds[i]), expr, fmt.Sprintf("control variable '%s'", name)); err != nil {
+				return err
+			}
+		}
+		for i := first; ; i += step {
+			env.Set(name, fromOrdinal(typ, i))
+			if err := EvalStmt(s.Body, env); err != nil {
+				return err
+			}
+			// Stop before stepping past the final value, which might overflow.
+			if i == last {
+				break
+			}
+		}
+	}
+
+	env.Set(name, undefined{
+		typ:    typ,
+		reason: "The control variable of a for loop is undefined once the loop has finished.",
+	})
+	return nil
+}
+
+// evalWith runs the body of a with statement in an environment where the
+// fields of each record are bound to references to them. The record
+// variables are accessed once, before the body runs, so the body changing
+// an index used to select one does not change which record it uses.
+func evalWith(s *parser.WithStmt, env *Environment) error {
+	scope := env
+	for _, expr := range s.Records {
+		target, err := evalRef(expr, scope)
+		if err != nil {
+			return err
+		}
+		r, ok := target.get().(record)
+		if !ok {
+			return &PascalError{
+				Msg:    "Expected a record variable in with statement",
+				Detail: fmt.Sprintf("%s is %s, not a record.", capitalize(describe(expr)), typeName(target.get())),
+				Hint:   "Only the fields of records can be brought into scope with 'with'.",
+				Pos:    expr.Pos(),
+				End:    expr.End(),
+			}
+		}
+
+		// A heap variable cannot be disposed while its fields are in scope.
+		c := heapCell(target)
+		if c != nil {
+			c.hold(expr)
+			defer c.release()
+		}
+
+		scope = NewEnclosedEnvironment(scope)
+		for i, field := range r.typ.Fields {
+			scope.declareVar(field.Name, field.Type, fieldRef{record: r, i: i, checked: !env.dialect.UncheckedVariants, cell: c})
+		}
+	}
+	return EvalStmt(s.Body, scope)
+}
+
+// evalCase runs the arm whose labels include the selector's value, or the
+// else arm. It is an error for no arm to match when there is no else arm.
+func evalCase(s *parser.CaseStmt, env *Environment) error {
+	selector, err := EvalExpr(s.Selector, env)
+	if err != nil {
+		return err
+	}
+	n, ok := ordinal(selector)
+	if !ok {
+		return &PascalError{
+			Msg:    "Case selector must be of an ordinal type",
+			Detail: fmt.Sprintf("The selector evaluated to a %s value.", typeName(selector)),
+			Hint:   "Use an integer, boolean, char or enumerated expression as the case selector.",
+			Pos:    s.Selector.Pos(),
+			End:    s.Selector.End(),
+		}
+	}
+
+	for _, arm := range s.Arms {
+		for _, label := range arm.Labels {
+			matched, err := caseLabelMatches(label, selector, n, env)
+			if err != nil {
+				return err
+			}
+			if matched {
+				return EvalStmt(arm.Body, env)
+			}
+		}
+	}
+
+	if s.HasElse {
+		for _, stmt := range s.Else {
+			if err := EvalStmt(stmt, env); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return &PascalError{
+		Msg:    "No case label matches the selector",
+		Detail: fmt.Sprintf("The selector evaluated to %s, which none of the case labels lists.", formatValue(selector)),
+		Hint:   "Add a label for this value, or an 'else' arm to handle every other value.",
+		Pos:    s.Selector.Pos(),
+		End:    s.Selector.End(),
+	}
+}
+
+func caseLabelMatches(label *parser.CaseLabel, selector Value, n int, env *Environment) (bool, error) {
+	bounds := []parser.Expr{label.Low}
+	if label.High != nil {
+		bounds = append(bounds, label.High)
+	}
+
+	ords := make([]int, 0, 2)
+	for _, expr := range bounds {
+		val, err := EvalExpr(expr, env)
+		if err != nil {
+			return false, err
+		}
+		if !compatible(typeOf(selector), val) {
+			return false, &PascalError{
+				Msg:    "Type mismatch in case label",
+				Detail: fmt.Sprintf("The label is %s, but the selector is %s.", typeName(val), typeName(selector)),
+				Hint:   "Case labels must have the same type as the selector.",
+				Pos:    expr.Pos(),
+				End:    expr.End(),
+			}
+		}
+		ord, _ := ordinal(val)
+		ords = append(ords, ord)
+	}
+
+	if len(ords) == 1 {
+		return n == ords[0], nil
+	}
+	return ords[0] <= n && n <= ords[1], nil
+}
+
+// checkRange reports an error if the ordinal value v, about to be stored in
+// what, lies outside the subrange type t, or if the set v has a member
+// outside the base type of the set type t.
+func checkRange(t parser.Type, v Value, at parser.Node, what string) error {
+	if s, ok := t.(*parser.SetType); ok {
+		return checkSetRange(s, v, at, what)
+	}
+	if _, ok := t.(*parser.SubrangeType); !ok {
+		return nil
+	}
+	n, _ := ordinal(v)
+	if low, high := parser.Bounds(t); n < low || n > high {
+		return &PascalError{
+			Msg:    "Value out of range",
+			Detail: fmt.Sprintf("%s can only hold values in %s, but the value is %s.", capitalize(what), rangeString(t), formatValue(v)),
+			Hint:   "Check the value before storing it, or widen the declared range.",
+			Pos:    at.Pos(),
+			End:    at.End(),
+		}
+	}
+	return nil
+}
+
+// rangeString returns the subrange type t as 'low..high', followed by its
+// name if it has one.
+func rangeString(t parser.Type) string {
+	low, high := parser.Bounds(t)
+	s := parser.FormatOrdinal(t, low) + ".." + parser.FormatOrdinal(t, high)
+	if name := t.String(); name != s {
+		s += " (" + name + ")"
+	}
+	return s
+}
+
+func functionStmtError(s *parser.CallStmt, name string, labels []diagnostics.Label) error {
+	return &PascalError{
+		Msg:    fmt.Sprintf("Function '%s' called as a statement", name),
+		Detail: "The result of a function call must be used, e.g. assigned to a variable.",
+		Hint:   fmt.Sprintf("Write 'x := %s(...)', or declare '%s' as a procedure.", name, name),
+		Pos:    s.Pos(),
+		End:    s.End(),
+		Labels: labels,
+	}
+}
+
+func constantError(decl *parser.ConstDecl, at parser.Node) error {
+	var labels []diagnostics.Label
+	if decl.Pos().IsValid() {
+		labels = []diagnostics.Label{{Pos: decl.Pos(), End: decl.End(), Msg: "constant declared here"}}
+	}
+	return &PascalError{
+		Msg:    fmt.Sprintf("Cannot assign to constant '%s'", decl.Name),
+		Detail: "A constant keeps the value it was declared with for the whole program.",
+		Hint:   fmt.Sprintf("Declare '%s' with 'var' if it needs to change.", decl.Name),
+		Pos:    at.Pos(),
+		End:    at.End(),
+		Labels: labels,
+	}
+}
+
+func controlVariableError(name string, at parser.Node, loop *parser.ForStmt) error {
+	return &PascalError{
+		Msg:    fmt.Sprintf("Assignment to for-loop control variable '%s'", name),
+		Detail: "The control variable of a for loop may not be changed while the loop is running.",
+		Hint:   "Use a while loop if the loop needs to adjust its own counter.",
+		Pos:    at.Pos(),
+		End:    at.End(),
+		Labels: []diagnostics.Label{{Pos: loop.Variable.Pos(), End: loop.Variable.End(), Msg: "controlled by this loop"}},
+	}
+}
+
+// evalCondition evaluates the condition of a control statement, which must
+// be boolean. keyword names the statement for the error message.
+func evalCondition(expr parser.Expr, keyword string, env *Environment) (bool, error) {
+	val, err := EvalExpr(expr, env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := val.(bool)
+	if !ok {
+		return false, &PascalError{
+			Msg:    fmt.Sprintf("Condition of '%s' must be boolean", keyword),
+			Detail: fmt.Sprintf("The condition evaluated to %s %s, not to true or false.", typeName(val), formatValue(val)),
+			Hint:   "Compare the value explicitly, e.g. 'x <> 0'.",
+			Pos:    expr.Pos(),
+			End:    expr.End(),
+		}
+	}
+	return b, nil
+}
+
+func evalUnary(e *parser.UnaryExpr, env *Environment) (Value, error) {
+	operand, err := EvalExpr(e.Operand, env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Operator.Type {
+	case token.NOT:
+		b, ok := operand.(bool)
+		if !ok {
+			return nil, &PascalError{
+				Msg:    "Type mismatch",
+				Detail: fmt.Sprintf("Operator 'not' needs a boolean operand, but got %s.", typeName(operand)),
+				Hint:   "Use 'not' only with boolean values, such as comparisons.",
+				Pos:    e.Operand.Pos(),
+				End:    e.Operand.End(),
+			}
+		}
+		return !b, nil
+	case token.PLUS, token.MINUS:
+		neg := e.Operator.Type == token.MINUS
+		switch v := operand.(type) {
+		case int:
+			if !neg {
+				return v, nil
+			}
+			n, ok := parser.IntegerOp(token.MINUS, 0, v)
+			if !ok {
+				return nil, integerOverflow(e, fmt.Sprintf("-(%d)", v))
+			}
+			return n, nil
+		case float64:
+			if neg {
+				return -v, nil
+			}
+			return v, nil
+		}
+		return nil, &PascalError{
+			Msg:    "Type mismatch",
+			Detail: fmt.Sprintf("Operator '%s' needs an integer or real operand, but got %s.", e.Operator.Literal, typeName(operand)),
+			Hint:   "A sign can only be applied to numbers.",
+			Pos:    e.Operand.Pos(),
+			End:    e.Operand.End(),
+		}
+	default:
+		return nil, &PascalError{
+			Msg:    "Unknown operator",
+			Detail: fmt.Sprintf("Operator '%s' is not supported.", e.Operator.Literal),
+			Hint:   "Use 'not', '+' or '-' as a prefix operator.",
+			Pos:    e.Operator.Pos,
+			End:    e.Operator.End,
+		}
+	}
+}
+
+func evalBinary(e *parser.BinaryExpr, env *Environment) (Value, error) {
+	left, err := EvalExpr(e.Left, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Operator.Type == token.AND || e.Operator.Type == token.OR {
+		return evalLogical(e, left, env)
+	}
+
+	right, err := EvalExpr(e.Right, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Operator.Type == token.IN {
+		return evalIn(e, left, right)
+	}
+	if l, r, ok := realOperands(left, right); ok && e.Operator.Type != token.DIV && e.Operator.Type != token.MOD {
+		return evalRealOp(e, l, r)
+	}
+	if l, r, ok := stringOperands(e, left, right); ok {
+		return evalStringOp(e, l, r)
+	}
+
+	switch l := left.(type) {
+	case int:
+		if r, ok := right.(int); ok {
+			return evalIntegerOp(e, l, r, env)
+		}
+	case bool:
+		if _, ok := right.(bool); ok {
+			return evalRelational(e, left, right)
+		}
+	case char:
+		if _, ok := right.(char); ok {
+			return evalRelational(e, left, right)
+		}
+	case enum:
+		if r, ok := right.(enum); ok && r.typ == l.typ {
+			return evalRelational(e, left, right)
+		}
+	case pointer:
+		if r, ok := right.(pointer); ok && (l.typ == nil || r.typ == nil || l.typ == r.typ) {
+			return evalPointerOp(e, l, r)
+		}
+	case set:
+		if r, ok := right.(set); ok && sameBase(l.typ.Base, r.typ.Base) {
+			return evalSetOp(e, l, r)
+		}
+	}
+	return nil, operandError(e, left, right)
+}
+
+func evalIntegerOp(e *parser.BinaryExpr, left, right int, env *Environment) (Value, error) {
+	switch e.Operator.Type {
+	case token.PLUS, token.MINUS, token.STAR:
+		v, ok := parser.IntegerOp(e.Operator.Type, left, right)
+		if !ok {
+			return nil, integerOverflow(e, fmt.Sprintf("%d %s %d", left, e.Operator.Literal, right))
+		}
+		return v, nil
+	case token.SLASH:
+		return evalRealOp(e, float64(left), float64(right))
+	case token.DIV:
+		if right == 0 {
+			return nil, divisionByZero(e)
+		}
+		return left / right, nil
+	case token.MOD:
+		return evalMod(e, left, right, env)
+	case token.EQUAL:
+		return left == right, nil
+	case token.NEQ:
+		return left != right, nil
+	case token.LT:
+		return left < right, nil
+	case token.LE:
+		return left <= right, nil
+	case token.GT:
+		return left > right, nil
+	case token.GE:
+		return left >= right, nil
+	default:
+		return nil, operandError(e, left, right)
+	}
+}
+
+// evalMod evaluates 'left mod right'. Under ISO rules right must be positive
+// and the result lies in 0..right-1; with the dialect's TruncatedMod it is
+// the remainder of 'left div right', which has the sign of left.
+func evalMod(e *parser.BinaryExpr, left, right int, env *Environment) (Value, error) {
+	if right == 0 {
+		return nil, divisionByZero(e)
+	}
+	if env.dialect.TruncatedMod {
+		return left % right, nil
+	}
+	if right < 0 {
+		return nil, &PascalError{
+			Msg:    "Negative modulus",
+			Detail: fmt.Sprintf("The right operand of 'mod' must be positive, but it is %d.", right),
+			Hint:   "Take the modulus by the absolute value of the divisor.",
+			Pos:    e.Right.Pos(),
+			End:    e.Right.End(),
+		}
+	}
+	m := left % right
+	if m < 0 {
+		m += right
+	}
+	return m, nil
+}
+
+// divisionByZero reports the division in e by a zero right operand, with
+// '/', 'div' or 'mod'.
+func divisionByZero(e *parser.BinaryExpr) error {
+	return &PascalError{
+		Msg:    "Division by zero",
+		Detail: fmt.Sprintf("The right operand of '%s' is zero.", e.Operator.Literal),
+		Hint:   "Ensure the divisor is not zero before performing division.",
+		Pos:    e.Right.Pos(),
+		End:    e.Right.End(),
+	}
+}
+
+// integerOverflow reports that the integer operation e, written out with
+// its operands' values as expr, has a result outside the range of integer.
+func integerOverflow(e parser.Expr, expr string) error {
+	return &PascalError{
+		Msg:    "Integer overflow",
+		Detail: fmt.Sprintf("The result of %s lies outside -maxint..maxint, the range of integer.", expr),
+		Hint:   "Check the operands before the operation, or use real arithmetic for values this large.",
+		Pos:    e.Pos(),
+		End:    e.End(),
+	}
+}
+
+// realOperands returns the operands of a binary operator as reals if one is
+// a real and the other a real or an integer, which is widened.
+func realOperands(left, right Value) (l, r float64, ok bool) {
+	_, leftReal := left.(float64)
+	_, rightReal := right.(float64)
+	if !leftReal && !rightReal {
+		return 0, 0, false
+	}
+	l, okl := numeric(left)
+	r, okr := numeric(right)
+	return l, r, okl && okr
+}
+
+// evalRealOp applies an arithmetic or relational operator to two reals. '/'
+// always divides reals, even when both operands are integers.
+func evalRealOp(e *parser.BinaryExpr, left, right float64) (Value, error) {
+	var v float64
+	switch e.Operator.Type {
+	case token.PLUS:
+		v = left + right
+	case token.MINUS:
+		v = left - right
+	case token.STAR:
+		v = left * right
+	case token.SLASH:
+		if right == 0 {
+			return nil, divisionByZero(e)
+		}
+		v = left / right
+	case token.EQUAL:
+		return left == right, nil
+	case token.NEQ:
+		return left != right, nil
+	case token.LT:
+		return left < right, nil
+	case token.LE:
+		return left <= right, nil
+	case token.GT:
+		return left > right, nil
+	case token.GE:
+		return left >= right, nil
+	default:
+		return nil, operandError(e, left, right)
+	}
+	if math.IsInf(v, 0) {
+		return nil, &PascalError{
+			Msg:    "Real overflow",
+			Detail: fmt.Sprintf("The result of '%s' is too large to be represented as a real.", e.Operator.Literal),
+			Hint:   "Scale the values down before combining them.",
+			Pos:    e.Pos(),
+			End:    e.End(),
+		}
+	}
+	return v, nil
+}
+
+// stringOperands returns the operands of a binary operator as strings if one
+// is a string or a value of a string type and the other is one too or a
+// char, or if both are chars joined with '+'.
+func stringOperands(e *parser.BinaryExpr, left, right Value) (l, r string, ok bool) {
+	if !isString(left) && !isString(right) && e.Operator.Type != token.PLUS {
+		return "", "", false
+	}
+	l, okl := asString(left)
+	r, okr := asString(right)
+	return l, r, okl && okr
+}
+
+// evalStringOp concatenates two strings with '+', or compares them in
+// dictionary order by their character codes.
+func evalStringOp(e *parser.BinaryExpr, left, right string) (Value, error) {
+	switch e.Operator.Type {
+	case token.PLUS:
+		return left + right, nil
+	case token.EQUAL:
+		return left == right, nil
+	case token.NEQ:
+		return left != right, nil
+	case token.LT:
+		return left < right, nil
+	case token.LE:
+		return left <= right, nil
+	case token.GT:
+		return left > right, nil
+	case token.GE:
+		return left >= right, nil
+	default:
+		return nil, operandError(e, left, right)
+	}
+}
+
+// evalRelational applies a relational operator to two values of the same
+// ordinal type by comparing their ordinal numbers. As in ISO Pascal,
+// false < true.
+func evalRelational(e *parser.BinaryExpr, left, right Value) (Value, error) {
+	l, _ := ordinal(left)
+	r, _ := ordinal(right)
+	switch e.Operator.Type {
+	case token.EQUAL:
+		return l == r, nil
+	case token.NEQ:
+		return l != r, nil
+	case token.LT:
+		return l < r, nil
+	case token.LE:
+		return l <= r, nil
+	case token.GT:
+		return l > r, nil
+	case token.GE:
+		return l >= r, nil
+	default:
+		return nil, operandError(e, left, right)
+	}
+}
+
+// evalPointerOp compares two pointers, which are equal if they point to the
+// same variable or are both nil.
+func evalPointerOp(e *parser.BinaryExpr, left, right pointer) (Value, error) {
+	switch e.Operator.Type {
+	case token.EQUAL:
+		return left.cell == right.cell, nil
+	case token.NEQ:
+		return left.cell != right.cell, nil
+	default:
+		return nil, operandError(e, left, right)
+	}
+}
+
+// evalLogical evaluates 'and' and 'or'. The right operand is skipped when the
+// left one decides the result and the dialect uses short-circuit evaluation.
+func evalLogical(e *parser.BinaryExpr, left Value, env *Environment) (Value, error) {
+	l, ok := left.(bool)
+	if !ok {
+		return nil, logicalOperandError(e, e.Left, left)
+	}
+
+	if env.dialect.ShortCircuit {
+		if e.Operator.Type == token.AND && !l {
+			return false, nil
+		}
+		if e.Operator.Type == token.OR && l {
+			return true, nil
+		}
+	}
+
+	right, err := EvalExpr(e.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := right.(bool)
+	if !ok {
+		return nil, logicalOperandError(e, e.Right, right)
+	}
+
+	if e.Operator.Type == token.AND {
+		return l && r, nil
+	}
+	return l || r, nil
+}
+
+// operandError reports a binary operator applied to operands it does not
+// accept, labelling each operand with its type.
+func operandError(e *parser.BinaryExpr, left, right Value) error {
+	hint := "Arithmetic needs integer or real operands; booleans can only be compared or combined with 'and', 'or' and 'not'."
+	_, leftSet := left.(set)
+	_, rightSet := right.(set)
+	if op := e.Operator.Type; op == token.DIV || op == token.MOD {
+		hint = "'div' and 'mod' need integer operands; use '/' to divide reals."
+	}
+	if leftSet || rightSet || e.Operator.Type == token.IN {
+		hint = "Sets of the same type are combined with '+', '*' and '-' and compared with '=', '<>', '<=' and '>='; 'x in s' needs x to be of the base type of s."
+	}
+	return &PascalError{
+		Msg:    "Type mismatch",
+		Detail: fmt.Sprintf("Operator '%s' cannot be applied to %s and %s operands.", e.Operator.Literal, typeName(left), typeName(right)),
+		Hint:   hint,
+		Pos:    e.Operator.Pos,
+		End:    e.Operator.End,
+		Labels: []diagnostics.Label{
+			{Pos: e.Left.Pos(), End: e.Left.End(), Msg: typeName(left)},
+			{Pos: e.Right.Pos(), End: e.Right.End(), Msg: typeName(right)},
+		},
+	}
+}
+
+func logicalOperandError(e *parser.BinaryExpr, operand parser.Expr, v Value) error {
+	return &PascalError{
+		Msg:    "Type mismatch",
+		Detail: fmt.Sprintf("Operator '%s' needs boolean operands, but got %s.", e.Operator.Literal, typeName(v)),
+		Hint:   "Parenthesize comparisons used with 'and' and 'or', e.g. '(a < b) and (b < c)'.",
+		Pos:    operand.Pos(),
+		End:    operand.End(),
+		Labels: []diagnostics.Label{{Pos: e.Operator.Pos, End: e.Operator.End, Msg: "operator"}},
+	}
+}
+
+// nodePos returns the position of node, tolerating nil nodes.
+func nodePos(node parser.Node) token.Position {
+	if node == nil {
+		return token.Position{}
+	}
+	return node.Pos()
+}
+
+// nodeEnd returns the end position of node, tolerating nil nodes.
+func nodeEnd(node parser.Node) token.Position {
+	if node == nil {
+		return token.Position{}
+	}
+	return node.End()
+}
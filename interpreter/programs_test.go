@@ -0,0 +1,370 @@
+package interpreter_test
+
+import (
+	"errors"
+	"testing"
+
+	"pastel/dialect"
+	"pastel/interpreter"
+	"pastel/parser"
+)
+
+// TestPrograms runs small programs and checks what they write or, for a
+// program that fails, the message of the error it stops with.
+func TestPrograms(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+		err  string
+	}{
+		{
+			name: "string shortened while assigning a character",
+			src: `program p;
+var s: string;
+function f: char;
+begin
+  s := 'a';
+  f := 'z'
+end;
+begin
+  s := 'hello';
+  s[5] := f
+end.`,
+			err: "String index out of range",
+		},
+		{
+			name: "var parameter bound to an element of a reassigned array",
+			src: `program p;
+type row = array[1..2] of integer;
+var a, b: row;
+procedure q(var x: integer);
+begin
+  a := b;
+  x := 5
+end;
+begin
+  b[1] := 1;
+  b[2] := 2;
+  q(a[1]);
+  writeln(a[1], ' ', a[2])
+end.`,
+			want: "5 2\n",
+		},
+		{
+			name: "var parameter bound to a field of a reassigned nested record",
+			src: `program p;
+type
+  inner = record n: integer end;
+  outer = record i: inner end;
+var r, s: outer;
+procedure q(var x: integer);
+begin
+  r.i := s.i;
+  x := 7
+end;
+begin
+  s.i.n := 3;
+  q(r.i.n);
+  writeln(r.i.n, ' ', s.i.n)
+end.`,
+			want: "7 3\n",
+		},
+		{
+			name: "with statement over a record that is reassigned",
+			src: `program p;
+type point = record a, b: integer end;
+var r, q: point;
+begin
+  q.a := 5;
+  q.b := 6;
+  with r do
+  begin
+    a := 10;
+    r := q;
+    writeln(a);
+    b := 7
+  end;
+  writeln(r.a, ' ', r.b)
+end.`,
+			want: "5\n5 7\n",
+		},
+		{
+			name: "predeclared names redeclared",
+			src: `program redeclare;
+const true = 0; false = 1;
+type boolean = integer;
+var x: boolean;
+procedure show(read: integer);
+var write: integer;
+begin
+  write := read * 2;
+  writeln(write)
+end;
+begin
+  x := true + false;
+  show(x)
+end.`,
+			want: "2\n",
+		},
+		{
+			name: "read used as a value",
+			src: `program value;
+var x: integer;
+begin
+  x := read
+end.`,
+			err: "Procedure 'read' used as a value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := run(tt.name, tt.src, "")
+			if msg := message(err); msg != tt.err {
+				t.Fatalf("error %q, want %q", msg, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("output %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReadNumber reads numbers in the syntax of ISO 6.1.5 and rejects
+// anything else as malformed.
+func TestReadNumber(t *testing.T) {
+	const src = `program readnumber;
+var i: integer; x: real;
+begin
+  read(i, x);
+  writeln(i, ' ', x:1:2)
+end.`
+	tests := []struct {
+		input string
+		want  string
+		err   string
+	}{
+		{input: "12 3.25", want: "12 3.25\n"},
+		{input: "-12 +3", want: "-12 3.00\n"},
+		{input: "0 2.5e-1", want: "0 0.25\n"},
+		{input: "0 1E2", want: "0 100.00\n"},
+		{input: "x 1.5", err: "Malformed integer in input"},
+		{input: "+ 1.5", err: "Malformed integer in input"},
+		{input: "1 .5", err: "Malformed real in input"},
+		{input: "1 1.", err: "Malformed real in input"},
+		{input: "1 1.e3", err: "Malformed real in input"},
+		{input: "1 1e", err: "Malformed real in input"},
+		{input: "1 1e+", err: "Malformed real in input"},
+		{input: "99999999999999999999 1", err: "Malformed integer in input"},
+		{input: "1", err: "Read past end of file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := run("readnumber.pas", src, tt.input)
+			if msg := message(err); msg != tt.err {
+				t.Fatalf("error %q, want %q", msg, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("output %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFieldWidth checks that a field width or number of decimal places of
+// 0 is an error, except in the dialects that allow it.
+func TestFieldWidth(t *testing.T) {
+	tests := []struct {
+		dialect dialect.Dialect
+		write   string
+		want    string
+		err     string
+	}{
+		{dialect: dialect.Pastel, write: "7:1", want: "7\n"},
+		{dialect: dialect.Pastel, write: "7:0", err: "Zero field width"},
+		{dialect: dialect.ISO, write: "'abc':0", err: "Zero field width"},
+		{dialect: dialect.ISO, write: "2.5:1:0", err: "Zero number of decimal places"},
+		{dialect: dialect.ISO, write: "2.5:0:1", err: "Zero field width"},
+		{dialect: dialect.ISO, write: "7:-1", err: "Negative field width"},
+		{dialect: dialect.Turbo, write: "7:0", want: "7\n"},
+		{dialect: dialect.Turbo, write: "2.5:0:0", want: "2\n"},
+		{dialect: dialect.FPC, write: "'abc':0", want: "abc\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name+" "+tt.write, func(t *testing.T) {
+			src := "program width;\nbegin\n  writeln(" + tt.write + ")\nend."
+			got, err := runDialect(tt.dialect, "width.pas", src, "")
+			if msg := message(err); msg != tt.err {
+				t.Fatalf("error %q, want %q", msg, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("output %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRuntimeErrors checks the message and detail of runtime errors.
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect dialect.Dialect
+		src     string
+		err     string
+		detail  string
+	}{
+		{
+			name: "array index out of range",
+			src: `program bounds;
+var a: array[1..3] of integer; i: integer;
+begin
+  i := 4;
+  a[i] := 1
+end.`,
+			err:    "Array index out of range",
+			detail: "Index 4 is outside 1..3, the index range of 'a'.",
+		},
+		{
+			name: "subrange value out of range",
+			src: `program bounds;
+var s: 1..10; i: integer;
+begin
+  i := 11;
+  s := i
+end.`,
+			err:    "Value out of range",
+			detail: "'s' can only hold values in 1..10, but the value is 11.",
+		},
+		{
+			name: "succ of the last value",
+			src: `program bounds;
+type day = (mon, tue);
+var d: day;
+begin
+  d := tue;
+  d := succ(d)
+end.`,
+			err:    "'succ' out of range",
+			detail: "tue is the last value of day, so it has no successor.",
+		},
+		{
+			name: "chr of a number above the last character",
+			src: `program bounds;
+var c: char; i: integer;
+begin
+  i := 300;
+  c := chr(i)
+end.`,
+			err: "'chr' out of range",
+		},
+		{
+			name: "nil dereference",
+			src: `program deref;
+var p: ^integer;
+begin
+  p := nil;
+  p^ := 1
+end.`,
+			err: "Dereference of nil pointer",
+		},
+		{
+			name: "disposed dereference",
+			src: `program deref;
+var p, q: ^integer;
+begin
+  new(p);
+  q := p;
+  dispose(p);
+  writeln(q^)
+end.`,
+			err: "Dereference of disposed pointer",
+		},
+		{
+			name: "mod by zero",
+			src: `program modulus;
+var i, j: integer;
+begin
+  i := 7; j := 0;
+  writeln(i mod j)
+end.`,
+			err: "Division by zero",
+		},
+		{
+			name: "mod by a negative number",
+			src: `program modulus;
+var i, j: integer;
+begin
+  i := 7; j := -2;
+  writeln(i mod j)
+end.`,
+			err: "Negative modulus",
+		},
+		{
+			name:    "mod by a negative number in turbo",
+			dialect: dialect.Turbo,
+			src: `program modulus;
+var i, j: integer;
+begin
+  i := 7; j := -2;
+  writeln(i mod j)
+end.`,
+		},
+		{
+			name: "integer overflow",
+			src: `program overflow;
+var i: integer;
+begin
+  i := maxint;
+  i := i + 1
+end.`,
+			err: "Integer overflow",
+		},
+		{
+			name: "integer overflow below -maxint",
+			src: `program overflow;
+var i: integer;
+begin
+  i := -maxint;
+  i := i - 1
+end.`,
+			err: "Integer overflow",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.dialect
+			if d.Name == "" {
+				d = dialect.Pastel
+			}
+			_, err := runDialect(d, "errors.pas", tt.src, "")
+			if msg := message(err); msg != tt.err {
+				t.Fatalf("error %q, want %q", msg, tt.err)
+			}
+			var re *interpreter.PascalError
+			if tt.detail != "" && errors.As(err, &re) && re.Detail != tt.detail {
+				t.Errorf("detail %q, want %q", re.Detail, tt.detail)
+			}
+		})
+	}
+}
+
+// message returns the message of err, a parser or runtime error, without
+// the rendered source, or "" if err is nil.
+func message(err error) string {
+	var pe *parser.ParserError
+	var re *interpreter.PascalError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &pe):
+		return pe.Msg
+	case errors.As(err, &re):
+		return re.Msg
+	default:
+		return err.Error()
+	}
+}
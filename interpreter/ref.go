@@ -0,0 +1,334 @@
+package interpreter
+
+import (
+	"fmt"
+	"pastel/parser"
+)
+
+// ref refers to a storage location: a variable, or a component of one. A var parameter is bound to a ref, so reading and assigning the
+// parameter reads and assigns the caller's variable.
+type ref interface {
+	get() Value
+	set(Value)
+	typ() parser.Type // the declared type of the location
+}
+
+// varRef refers to the variable name stored directly in env.
+type varRef struct {
+	env  *Environment
+	name string
+}
+
+func (r varRef) get() Value  { return r.env.store[r.name] }
+func (r varRef) set(v Value) { r.env.store[r.name] = overwrite(r.env.store[r.name], v) }
+
+func (r varRef) typ() parser.Type { return r.env.types[r.name] }
+
+// elemRef refers to component i of an array, counting from 0. It shares
+// the array's storage, so setting it updates the array in place. cell is
+// the heap variable the array is part of, or nil.
+type elemRef struct {
+	array array
+	i     int
+	cell  *cell
+}
+
+func (r elemRef) get() Value       { return r.array.elems[r.i] }
+func (r elemRef) set(v Value)      { r.array.elems[r.i] = overwrite(r.array.elems[r.i], v) }
+func (r elemRef) typ() parser.Type { return r.array.typ.Elem }
+
+// charRef refers to character i of the string held by the variable base,
+// counting from 0. Setting it replaces the string in base. A string may
+// change length, so a charRef is never kept beyond the statement that
+// makes it; see bindParam.
+type charRef struct {
+	base ref
+	i    int
+}
+
+func (r charRef) get() Value       { return char(r.base.get().(string)[r.i]) }
+func (r charRef) typ() parser.Type { return parser.Char }
+
+func (r charRef) set(v Value) {
+	s := r.base.get().(string)
+	c, _ := asString(v)
+	r.base.set(s[:r.i] + c + s[r.i+1:])
+}
+
+// fieldRef refers to field i of a record, sharing the record's storage.
+// When checked is set, a field of a variant that is not active reads as
+// undefined. cell is the heap variable the record is part of, or nil.
+type fieldRef struct {
+	record  record
+	i       int
+	checked bool
+	cell    *cell
+}
+
+func (r fieldRef) field() *parser.Field { return r.record.typ.Fields[r.i] }
+func (r fieldRef) typ() parser.Type     { return r.field().Type }
+
+func (r fieldRef) get() Value {
+	f := r.field()
+	if r.checked && !r.record.isActive(f.Variant) {
+		return undefined{
+			typ:    f.Type,
+			reason: fmt.Sprintf("Field '%s' belongs to %s, which is not active.", f.Name, f.Variant),
+		}
+	}
+	return r.record.fields[r.i]
+}
+
+// set assigns the field. Assigning a tag field selects the variant its new
+// value labels, and assigning a field of a variant without a tag field
+// makes that variant the active one.
+func (r fieldRef) set(v Value) {
+	f := r.field()
+	for variant := f.Variant; variant != nil && variant.Part.Tag == nil; variant = variant.Part.Parent {
+		r.record.activate(variant)
+	}
+	r.record.fields[r.i] = overwrite(r.record.fields[r.i], v)
+	if f.Part != nil {
+		n, _ := ordinal(v)
+		r.record.selectVariant(f.Part, n)
+	}
+}
+
+// checkVariant reports an error if target, about to be assigned, is a field
+// of a variant that only its tag field can make active, and that variant is
+// not active.
+func checkVariant(r ref, target parser.Expr) error {
+	fr, ok := r.(fieldRef)
+	if !ok || !fr.checked {
+		return nil
+	}
+	f := fr.field()
+	for v := f.Variant; v != nil; v = v.Part.Parent {
+		if v.Part.Tag != nil && fr.record.active[v.Part.Index] != v {
+			return &PascalError{
+				Msg:    fmt.Sprintf("Assignment to inactive variant field '%s'", f.Name),
+				Detail: fmt.Sprintf("%s belongs to %s, which is not active.", capitalize(describe(target)), v),
+				Hint:   fmt.Sprintf("Set '%s' to select the variant before assigning its fields.", v.Part.Tag.Name),
+				Pos:    target.Pos(),
+				End:    target.End(),
+			}
+		}
+	}
+	return nil
+}
+
+// evalRef evaluates expr as a variable access and returns a reference to the
+// variable, for assigning to it or binding it to a var parameter.
+func evalRef(expr parser.Expr, env *Environment) (ref, error) {
+	switch e := expr.(type) {
+	case *parser.Identifier:
+		owner := env.owner(e.Value)
+		if owner == nil {
+			return nil, &PascalError{
+				Msg:    fmt.Sprintf("Undefined variable '%s'", e.Value),
+				Detail: "This variable is being used but was never declared.",
+				Hint:   fmt.Sprintf("Declare the variable using `var %s: integer;`.", e.Value),
+				Pos:    e.Pos(),
+				End:    e.End(),
+			}
+		}
+		switch v := owner.store[e.Value].(type) {
+		case ref:
+			// Passing a var parameter on passes the variable it refers to.
+			return v, nil
+		case constant, routine:
+			return nil, notVariableError(expr)
+		}
+		if loop := env.controlledBy(e.Value); loop != nil {
+			return nil, controlVariableError(e.Value, e, loop)
+		}
+		return varRef{env: owner, name: e.Value}, nil
+
+	case *parser.IndexExpr:
+		return evalIndex(e, env)
+
+	case *parser.FieldExpr:
+		return evalField(e, env)
+
+	case *parser.DerefExpr:
+		return evalDeref(e, env)
+
+	default:
+		return nil, notVariableError(expr)
+	}
+}
+
+// readRef returns the value of the variable access expr, which r refers
+// to, or an error if its value is undefined.
+func readRef(r ref, expr parser.Expr) (Value, error) {
+	val := r.get()
+	if u, ok := val.(undefined); ok {
+		return nil, &PascalError{
+			Msg:    fmt.Sprintf("%s is undefined", capitalize(describe(expr))),
+			Detail: u.reason,
+			Hint:   "Assign a value to it before reading it.",
+			Pos:    expr.Pos(),
+			End:    expr.End(),
+		}
+	}
+	return val, nil
+}
+
+// evalIndex evaluates the indexed variable e and returns a reference to the
+// component it selects, checking the index against the array's bounds.
+func evalIndex(e *parser.IndexExpr, env *Environment) (ref, error) {
+	base, err := evalRef(e.Array, env)
+	if err != nil {
+		return nil, err
+	}
+	val, err := readRef(base, e.Array)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := val.(string); ok {
+		return evalStringIndex(e, base, s, env)
+	}
+	a, ok := val.(array)
+	if !ok {
+		return nil, &PascalError{
+			Msg:    fmt.Sprintf("'%s' is not an array", rootName(e.Array)),
+			Detail: fmt.Sprintf("Only arrays can be indexed, but this is %s.", typeName(val)),
+			Hint:   "Remove the index, or index an array variable.",
+			Pos:    e.Array.Pos(),
+			End:    e.Array.End(),
+		}
+	}
+
+	index, err := EvalExpr(e.Index, env)
+	if err != nil {
+		return nil, err
+	}
+	if !compatible(a.typ.Index, index) {
+		return nil, &PascalError{
+			Msg:    "Type mismatch in array index",
+			Detail: fmt.Sprintf("'%s' is indexed by %s, but the index is %s.", rootName(e.Array), a.typ.Index, typeName(index)),
+			Hint:   "The index must have the index type of the array.",
+			Pos:    e.Index.Pos(),
+			End:    e.Index.End(),
+		}
+	}
+
+	n, _ := ordinal(index)
+	low, high := parser.Bounds(a.typ.Index)
+	if n < low || n > high {
+		return nil, &PascalError{
+			Msg:    "Array index out of range",
+			Detail: fmt.Sprintf("Index %s is outside %s, the index range of '%s'.", formatValue(index), rangeString(a.typ.Index), rootName(e.Array)),
+			Hint:   "Check the index before using it to access the array.",
+			Pos:    e.Index.Pos(),
+			End:    e.Index.End(),
+		}
+	}
+	return elemRef{array: a, i: n - low, cell: heapCell(base)}, nil
+}
+
+// evalStringIndex returns a reference to the character of s, the string
+// held by base, that e selects. The characters are numbered from 1.
+func evalStringIndex(e *parser.IndexExpr, base ref, s string, env *Environment) (ref, error) {
+	index, err := EvalExpr(e.Index, env)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := index.(int)
+	if !ok {
+		return nil, &PascalError{
+			Msg:    "Type mismatch in string index",
+			Detail: fmt.Sprintf("'%s' is a string, indexed by integer, but the index is %s.", rootName(e.Array), typeName(index)),
+			Hint:   "Index a string by the position of a character, counting from 1.",
+			Pos:    e.Index.Pos(),
+			End:    e.Index.End(),
+		}
+	}
+	if n < 1 || n > len(s) {
+		return nil, &PascalError{
+			Msg:    "String index out of range",
+			Detail: fmt.Sprintf("Index %d is outside 1..%d, the positions of the characters of '%s'.", n, len(s), rootName(e.Array)),
+			Hint:   "Check the index against length before using it to access the string.",
+			Pos:    e.Index.Pos(),
+			End:    e.Index.End(),
+		}
+	}
+	return charRef{base: base, i: n - 1}, nil
+}
+
+// evalField evaluates the field designator e and returns a reference to the
+// field it selects.
+func evalField(e *parser.FieldExpr, env *Environment) (ref, error) {
+	base, err := evalRef(e.Record, env)
+	if err != nil {
+		return nil, err
+	}
+	val, err := readRef(base, e.Record)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := val.(record)
+	if !ok {
+		return nil, &PascalError{
+			Msg:    fmt.Sprintf("%s is not a record", capitalize(describe(e.Record))),
+			Detail: fmt.Sprintf("Only records have fields, but this is %s.", typeName(val)),
+			Hint:   "Remove the field selector, or select a field of a record variable.",
+			Pos:    e.Record.Pos(),
+			End:    e.Record.End(),
+		}
+	}
+
+	i := r.typ.FieldIndex(e.Field)
+	if i < 0 {
+		return nil, &PascalError{
+			Msg:    fmt.Sprintf("Record has no field '%s'", e.Field),
+			Detail: fmt.Sprintf("%s is of type %s, which has no field called '%s'.", capitalize(describe(e.Record)), r.typ, e.Field),
+			Hint:   "Check the spelling of the field name against the record type.",
+			Pos:    e.FieldSpan.Pos(),
+			End:    e.FieldSpan.End(),
+		}
+	}
+	return fieldRef{record: r, i: i, checked: !env.dialect.UncheckedVariants, cell: heapCell(base)}, nil
+}
+
+// rootName returns the name of the variable that the variable access expr
+// selects a component of.
+func rootName(expr parser.Expr) string {
+	switch e := expr.(type) {
+	case *parser.Identifier:
+		return e.Value
+	case *parser.IndexExpr:
+		return rootName(e.Array)
+	case *parser.FieldExpr:
+		return rootName(e.Record)
+	case *parser.DerefExpr:
+		return rootName(e.Pointer)
+	default:
+		return "?"
+	}
+}
+
+// describe names the variable access expr for messages, e.g. "'x'",
+// "an element of 'a'", "field 'f' of 'r'" or "the variable 'p' points to".
+func describe(expr parser.Expr) string {
+	switch e := expr.(type) {
+	case *parser.IndexExpr:
+		return fmt.Sprintf("an element of '%s'", rootName(expr))
+	case *parser.FieldExpr:
+		return fmt.Sprintf("field '%s' of '%s'", e.Field, rootName(expr))
+	case *parser.DerefExpr:
+		return fmt.Sprintf("the variable %s points to", describe(e.Pointer))
+	default:
+		return fmt.Sprintf("'%s'", rootName(expr))
+	}
+}
+
+func notVariableError(expr parser.Expr) error {
+	return &PascalError{
+		Msg:    "Expected a variable",
+		Detail: "Only a variable can be assigned to or passed to a var parameter, not a value.",
+		Hint:   "Use a variable here, or make the parameter a value parameter by removing 'var'.",
+		Pos:    expr.Pos(),
+		End:    expr.End(),
+	}
+}
@@ -0,0 +1,237 @@
+package interpreter
+
+import (
+	"fmt"
+	"math/bits"
+	"pastel/parser"
+	"pastel/token"
+	"strings"
+)
+
+// bitset holds the ordinal numbers 0..parser.MaxSetOrdinal of the members
+// of a set, one bit each. It is an array, so copying a set copies its bits.
+type bitset [(parser.MaxSetOrdinal + 1) / 64]uint64
+
+func (b *bitset) add(n int) {
+	b[n/64] |= 1 << (n % 64)
+}
+
+func (b bitset) has(n int) bool {
+	return n >= 0 && n <= parser.MaxSetOrdinal && b[n/64]&(1<<(n%64)) != 0
+}
+
+func (b bitset) union(c bitset) bitset {
+	for i := range b {
+		b[i] |= c[i]
+	}
+	return b
+}
+
+func (b bitset) intersect(c bitset) bitset {
+	for i := range b {
+		b[i] &= c[i]
+	}
+	return b
+}
+
+func (b bitset) minus(c bitset) bitset {
+	for i := range b {
+		b[i] &^= c[i]
+	}
+	return b
+}
+
+// subset reports whether every member of b is a member of c.
+func (b bitset) subset(c bitset) bool {
+	for i := range b {
+		if b[i]&^c[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// members returns the ordinal numbers in b in ascending order.
+func (b bitset) members() []int {
+	var ns []int
+	for i, word := range b {
+		for word != 0 {
+			bit := bits.TrailingZeros64(word)
+			ns = append(ns, i*64+bit)
+			word &^= 1 << bit
+		}
+	}
+	return ns
+}
+
+// set is a value of a set type. The type of a set built by a constructor
+// is 'set of' the host type of its members, or 'set of nothing' for '[]'.
+type set struct {
+	typ  *parser.SetType
+	bits bitset
+}
+
+// base returns the host type of the members of s, or nil for the empty set
+// constructed by '[]'.
+func (s set) base() parser.Type {
+	if s.typ.Base == nil {
+		return nil
+	}
+	return parser.Host(s.typ.Base)
+}
+
+// sameBase reports whether sets with the base types a and b are compatible:
+// their hosts agree, or one of them is the type of '[]'.
+func sameBase(a, b parser.Type) bool {
+	return a == nil || b == nil || parser.Host(a) == parser.Host(b)
+}
+
+// evalSetConstructor evaluates a set constructor such as '[1, 3..5]'. The
+// members must be values of one ordinal type.
+func evalSetConstructor(e *parser.SetConstructor, env *Environment) (Value, error) {
+	var host parser.Type
+	var b bitset
+	for _, m := range e.Members {
+		bounds := []parser.Expr{m.Low}
+		if m.High != nil {
+			bounds = append(bounds, m.High)
+		}
+
+		var ords []int
+		for _, expr := range bounds {
+			v, err := EvalExpr(expr, env)
+			if err != nil {
+				return nil, err
+			}
+			n, ok := ordinal(v)
+			if !ok {
+				return nil, &PascalError{
+					Msg:    "Set member must be of an ordinal type",
+					Detail: fmt.Sprintf("The member is %s.", typeName(v)),
+					Hint:   "Sets hold integer, boolean, char or enumerated values.",
+					Pos:    expr.Pos(),
+					End:    expr.End(),
+				}
+			}
+			if t := parser.Host(typeOf(v)); host == nil {
+				host = t
+			} else if t != host {
+				return nil, &PascalError{
+					Msg:    "Type mismatch in set constructor",
+					Detail: fmt.Sprintf("The set holds %s values, but this member is %s.", host, typeName(v)),
+					Hint:   "All members of a set must be of the same type.",
+					Pos:    expr.Pos(),
+					End:    expr.End(),
+				}
+			}
+			ords = append(ords, n)
+		}
+
+		low, high := ords[0], ords[len(ords)-1]
+		if low > high {
+			continue // an empty range adds no members
+		}
+		if low < 0 || high > parser.MaxSetOrdinal {
+			return nil, &PascalError{
+				Msg:    "Set member out of range",
+				Detail: fmt.Sprintf("A set can only hold values with ordinal numbers 0..%d, but the member is %s.", parser.MaxSetOrdinal, formatRange(host, low, high)),
+				Hint:   "Keep set members small and non-negative.",
+				Pos:    m.Pos(),
+				End:    m.End(),
+			}
+		}
+		for n := low; n <= high; n++ {
+			b.add(n)
+		}
+	}
+	return set{typ: &parser.SetType{Base: host}, bits: b}, nil
+}
+
+// evalIn evaluates 'left in right', which tests whether the ordinal value
+// left is a member of the set right.
+func evalIn(e *parser.BinaryExpr, left, right Value) (Value, error) {
+	s, ok := right.(set)
+	n, isOrdinal := ordinal(left)
+	if !ok || !isOrdinal || !sameBase(typeOf(left), s.base()) {
+		return nil, operandError(e, left, right)
+	}
+	return s.bits.has(n), nil
+}
+
+// evalSetOp applies a set operator to two sets of the same base type: '+',
+// '*' and '-' are union, intersection and difference, and '<=' and '>='
+// test for inclusion.
+func evalSetOp(e *parser.BinaryExpr, left, right set) (Value, error) {
+	base := left.base()
+	if base == nil {
+		base = right.base()
+	}
+	result := func(b bitset) Value {
+		return set{typ: &parser.SetType{Base: base}, bits: b}
+	}
+
+	switch e.Operator.Type {
+	case token.PLUS:
+		return result(left.bits.union(right.bits)), nil
+	case token.STAR:
+		return result(left.bits.intersect(right.bits)), nil
+	case token.MINUS:
+		return result(left.bits.minus(right.bits)), nil
+	case token.EQUAL:
+		return left.bits == right.bits, nil
+	case token.NEQ:
+		return left.bits != right.bits, nil
+	case token.LE:
+		return left.bits.subset(right.bits), nil
+	case token.GE:
+		return right.bits.subset(left.bits), nil
+	default:
+		return nil, operandError(e, left, right)
+	}
+}
+
+// checkSetRange reports an error if the set v has a member outside the
+// base type of t, where v is about to be stored in what.
+func checkSetRange(t *parser.SetType, v Value, at parser.Node, what string) error {
+	s, ok := v.(set)
+	if !ok {
+		return nil
+	}
+	low, high := parser.Bounds(t.Base)
+	for _, n := range s.bits.members() {
+		if n < low || n > high {
+			return &PascalError{
+				Msg:    "Set member out of range",
+				Detail: fmt.Sprintf("%s can only hold members in %s, but the set contains %s.", capitalize(what), rangeString(t.Base), parser.FormatOrdinal(t.Base, n)),
+				Hint:   "Remove the member from the set before storing it, or widen the base type.",
+				Pos:    at.Pos(),
+				End:    at.End(),
+			}
+		}
+	}
+	return nil
+}
+
+// formatSet returns s as a set constructor, e.g. '[1, 3..5]'.
+func formatSet(s set) string {
+	var parts []string
+	ns := s.bits.members()
+	for i := 0; i < len(ns); {
+		j := i
+		for j+1 < len(ns) && ns[j+1] == ns[j]+1 {
+			j++
+		}
+		parts = append(parts, formatRange(s.typ.Base, ns[i], ns[j]))
+		i = j + 1
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// formatRange returns 'low..high' for values of t, or just low if the
+// range holds one value.
+func formatRange(t parser.Type, low, high int) string {
+	if low == high {
+		return parser.FormatOrdinal(t, low)
+	}
+	return parser.FormatOrdinal(t, low) + ".." + parser.FormatOrdinal(t, high)
+}
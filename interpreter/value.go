@@ -0,0 +1,456 @@
+package interpreter
+
+import (
+	"fmt"
+	"pastel/parser"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Value is a runtime Pascal value. Integers are held as int, reals as
+// float64, booleans as bool, characters as char, values of enumerated
+// types as enum, arrays and records as array and record, pointers as
+// pointer, sets as set, files as *file, and strings as string.
+//
+// Arrays and records are values, not references: assigning one or passing
+// it by value stores a copy, made by storedValue. The declared type of a
+// variable is kept beside its value, in Environment.types, as the value
+// does not always determine it: nil belongs to every pointer type, and an
+// integer to every subrange of integer.
+type Value any
+
+// undefined is stored in a variable whose value has become undefined, such
+// as the control variable of a finished for loop. It remembers the type of
+// the variable and why it is undefined.
+type undefined struct {
+	typ    parser.Type
+	reason string
+}
+
+// routine is the value bound to the name of a procedure or function. env
+// is the environment the routine was declared in, which its calls are
+// nested in.
+type routine struct {
+	decl *parser.RoutineDecl
+	env  *Environment
+}
+
+// constant is bound to a name declared with 'const'. Reading the name yields
+// val, which the parser has already folded from the declared expression.
+type constant struct {
+	decl *parser.ConstDecl
+	val  Value
+}
+
+// char is a value of type char. Characters are bytes, so a string holds
+// one char per byte.
+type char byte
+
+// stringValue returns the value of a string literal or constant s: a char
+// if s has one character, and otherwise a string.
+func stringValue(s string) Value {
+	if len(s) == 1 {
+		return char(s[0])
+	}
+	return s
+}
+
+// asString returns the string, char or value of a string type v as a
+// string.
+func asString(v Value) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case char:
+		return string([]byte{byte(v)}), true
+	case array:
+		if !parser.IsStringType(v.typ) {
+			return "", false
+		}
+		b := make([]byte, len(v.elems))
+		for i, elem := range v.elems {
+			b[i] = byte(elem.(char))
+		}
+		return string(b), true
+	default:
+		return "", false
+	}
+}
+
+// isString reports whether v is a string or a value of a string type.
+func isString(v Value) bool {
+	switch v := v.(type) {
+	case string:
+		return true
+	case array:
+		return parser.IsStringType(v.typ)
+	default:
+		return false
+	}
+}
+
+// enum is a value of an enumerated type.
+type enum struct {
+	typ *parser.EnumType
+	ord int
+}
+
+// array is a value of an array type. Its components are stored in elems,
+// in index order. Arrays are assigned and passed by value, so storing one
+// must copy it with copyValue.
+type array struct {
+	typ   *parser.ArrayType
+	elems []Value
+}
+
+// record is a value of a record type, holding the value of each field in
+// the order the fields are declared. Like arrays, records are copied when
+// stored. active holds the active variant of each variant part, or nil if
+// none is.
+type record struct {
+	typ    *parser.RecordType
+	fields []Value
+	active []*parser.Variant
+}
+
+// isActive reports whether the fields of variant v are in use: v and every
+// variant it is nested in are active. The fixed part, v == nil, always is.
+func (r record) isActive(v *parser.Variant) bool {
+	for ; v != nil; v = v.Part.Parent {
+		if r.active[v.Part.Index] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// selectVariant makes the variant of part whose labels include the tag
+// value n the active one.
+func (r record) selectVariant(part *parser.VariantPart, n int) {
+	for _, v := range part.Variants {
+		if slices.Contains(v.Labels, n) {
+			r.activate(v)
+			return
+		}
+	}
+	r.active[part.Index] = nil
+}
+
+// activate makes v the active variant of its part. A variant that becomes
+// active starts with fresh fields, as its previous values are undefined.
+func (r record) activate(v *parser.Variant) {
+	if r.active[v.Part.Index] == v {
+		return
+	}
+	r.active[v.Part.Index] = v
+	for _, part := range r.typ.Parts {
+		if part.Parent == v {
+			r.active[part.Index] = nil
+		}
+	}
+	for i, f := range r.typ.Fields {
+		if f.Variant == v {
+			r.fields[i] = zeroValue(f.Type)
+			if f.Part != nil {
+				n, _ := ordinal(r.fields[i])
+				r.selectVariant(f.Part, n)
+			}
+		}
+	}
+}
+
+// typeOf returns the type of the value v, or nil if v is not a value of a
+// data type.
+func typeOf(v Value) parser.Type {
+	switch v := v.(type) {
+	case int:
+		return parser.Integer
+	case float64:
+		return parser.Real
+	case bool:
+		return parser.Boolean
+	case char:
+		return parser.Char
+	case string:
+		return parser.String
+	case enum:
+		return v.typ
+	case array:
+		return v.typ
+	case record:
+		return v.typ
+	case pointer:
+		if v.typ == nil {
+			return nil
+		}
+		return v.typ
+	case set:
+		return v.typ
+	case *file:
+		return v.typ
+	case undefined:
+		return v.typ
+	default:
+		return nil
+	}
+}
+
+// typeName returns the Pascal name of v's type, for error messages.
+func typeName(v Value) string {
+	switch v := v.(type) {
+	case routine:
+		return v.decl.Kind()
+	case builtin:
+		return "required " + v.Kind()
+	case pointer:
+		if v.typ == nil {
+			return "nil"
+		}
+	}
+	if t := typeOf(v); t != nil {
+		return t.String()
+	}
+	return "unknown"
+}
+
+// compatible reports whether v is a value of type t, or of a type with the
+// same host type. nil is a value of every pointer type, a set is a value
+// of every set type whose base type has the same host type, and an integer
+// is widened to a real where a real is expected, as is a char to a string.
+// A string of n characters is a value of a string type of length n.
+func compatible(t parser.Type, v Value) bool {
+	if _, ok := v.(int); ok && t == parser.Real {
+		return true
+	}
+	if _, ok := v.(char); ok && t == parser.String {
+		return true
+	}
+	if parser.IsStringType(t) {
+		s, ok := asString(v)
+		_, n := parser.Bounds(t.(*parser.ArrayType).Index)
+		return ok && len(s) == n
+	}
+	if p, ok := v.(pointer); ok && p.typ == nil {
+		_, ok := t.(*parser.PointerType)
+		return ok
+	}
+	if s, ok := v.(set); ok {
+		st, ok := t.(*parser.SetType)
+		return ok && sameBase(st.Base, s.typ.Base)
+	}
+	vt := typeOf(v)
+	return vt != nil && parser.Host(vt) == parser.Host(t)
+}
+
+// zeroValue returns the initial value of a variable of type t.
+func zeroValue(t parser.Type) Value {
+	if a, ok := t.(*parser.ArrayType); ok {
+		low, high := parser.Bounds(a.Index)
+		elems := make([]Value, high-low+1)
+		for i := range elems {
+			elems[i] = zeroValue(a.Elem)
+		}
+		return array{typ: a, elems: elems}
+	}
+	if r, ok := t.(*parser.RecordType); ok {
+		rec := record{typ: r, fields: make([]Value, len(r.Fields)), active: make([]*parser.Variant, len(r.Parts))}
+		for i, f := range r.Fields {
+			rec.fields[i] = zeroValue(f.Type)
+		}
+		// The tags of the fixed part select their variants from the start.
+		for i, f := range r.Fields {
+			if f.Part != nil && f.Variant == nil {
+				n, _ := ordinal(rec.fields[i])
+				rec.selectVariant(f.Part, n)
+			}
+		}
+		return rec
+	}
+	if p, ok := t.(*parser.PointerType); ok {
+		return pointer{typ: p}
+	}
+	if s, ok := t.(*parser.SetType); ok {
+		return set{typ: s}
+	}
+	if f, ok := t.(*parser.FileType); ok {
+		return &file{typ: f}
+	}
+
+	if t == parser.Real {
+		return 0.0
+	}
+	if t == parser.String {
+		return ""
+	}
+
+	low, _ := parser.Bounds(t)
+	if t == parser.Integer {
+		low = 0
+	}
+	return fromOrdinal(t, low)
+}
+
+// ordinal returns the ordinal number of v, and false if v's type is not an
+// ordinal type.
+func ordinal(v Value) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case bool:
+		return boolOrd(v), true
+	case char:
+		return int(v), true
+	case enum:
+		return v.ord, true
+	default:
+		return 0, false
+	}
+}
+
+// numeric returns the integer or real v as a real.
+func numeric(v Value) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// fromOrdinal returns the value of the ordinal type t with ordinal number n.
+func fromOrdinal(t parser.Type, n int) Value {
+	switch t := parser.Host(t).(type) {
+	case *parser.EnumType:
+		return enum{typ: t, ord: n}
+	default:
+		if t == parser.Boolean {
+			return n != 0
+		}
+		if t == parser.Char {
+			return char(n)
+		}
+		return n
+	}
+}
+
+// copyValue returns a copy of v that shares no storage with it, for
+// assigning v or passing it by value.
+func copyValue(v Value) Value {
+	switch v := v.(type) {
+	case array:
+		return array{typ: v.typ, elems: copyValues(v.elems)}
+	case record:
+		return record{typ: v.typ, fields: copyValues(v.fields), active: slices.Clone(v.active)}
+	default:
+		return v
+	}
+}
+
+// overwrite returns the value a variable holding dst holds once v is
+// assigned to it. An array or record is updated in place, component by
+// component, so that references to its components, held by var
+// parameters and with statements, see the new value.
+func overwrite(dst, v Value) Value {
+	switch d := dst.(type) {
+	case array:
+		if a, ok := v.(array); ok && len(a.elems) == len(d.elems) {
+			for i, elem := range a.elems {
+				d.elems[i] = overwrite(d.elems[i], elem)
+			}
+			return d
+		}
+	case record:
+		if r, ok := v.(record); ok && r.typ == d.typ {
+			for i, field := range r.fields {
+				d.fields[i] = overwrite(d.fields[i], field)
+			}
+			copy(d.active, r.active)
+			return d
+		}
+	}
+	return v
+}
+
+// storedValue returns the value a variable of type t holds after v is
+// stored in it: a copy of v, where nil takes the pointer type t, a set
+// takes the set type t, an integer stored in a real becomes a real, and a
+// char stored in a string becomes a string.
+func storedValue(t parser.Type, v Value) Value {
+	if n, ok := v.(int); ok && t == parser.Real {
+		return float64(n)
+	}
+	if c, ok := v.(char); ok && t == parser.String {
+		s, _ := asString(c)
+		return s
+	}
+	if parser.IsStringType(t) {
+		if s, ok := asString(v); ok {
+			a := t.(*parser.ArrayType)
+			elems := make([]Value, len(s))
+			for i := range len(s) {
+				elems[i] = char(s[i])
+			}
+			return array{typ: a, elems: elems}
+		}
+	}
+	if p, ok := v.(pointer); ok && p.typ == nil {
+		if pt, ok := t.(*parser.PointerType); ok {
+			return pointer{typ: pt}
+		}
+	}
+	if s, ok := v.(set); ok {
+		if st, ok := t.(*parser.SetType); ok {
+			return set{typ: st, bits: s.bits}
+		}
+	}
+	return copyValue(v)
+}
+
+func copyValues(vals []Value) []Value {
+	copies := make([]Value, len(vals))
+	for i, v := range vals {
+		copies[i] = copyValue(v)
+	}
+	return copies
+}
+
+func boolOrd(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// formatValue returns v as writeln prints it, except that chars and strings
+// are quoted as in source, for messages.
+func formatValue(v Value) string {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strings.TrimPrefix(formatFloat(v, realWidth), " ")
+	case char:
+		return parser.FormatChar(int(v))
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case enum:
+		return v.typ.Values[v.ord]
+	case pointer:
+		if v.cell == nil {
+			return "NIL"
+		}
+		return fmt.Sprintf("^%d", v.cell.id)
+	case set:
+		return formatSet(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -1,24 +1,57 @@
 package lexer
 
 import (
+	"fmt"
+	"pastel/dialect"
 	"pastel/token"
+	"strconv"
 	"strings"
 )
 
+// Error is a lexical error, such as an unterminated comment. The parser
+// reports these alongside its own errors.
+type Error struct {
+	Msg    string
+	Detail string
+	Hint   string
+	Pos    token.Position
+	End    token.Position
+}
+
 type Lexer struct {
+	dialect      dialect.Dialect
+	errors       []*Error
+	filename     string
 	input        string
 	position     int
 	readPosition int
 	ch           byte
+	line         int // line of ch, starting at 1
+	column       int // column of ch, starting at 1
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input, dialect.Pastel)
+}
+
+// NewFile creates a lexer for the given dialect whose token positions carry
+// the given file name.
+func NewFile(filename, input string, d dialect.Dialect) *Lexer {
+	l := &Lexer{dialect: d, filename: filename, input: input, line: 1}
 	l.readChar()
 	return l
 }
 
+// Errors returns the lexical errors found so far.
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -26,12 +59,28 @@ func (l *Lexer) readChar() {
 	}
 	l.position = l.readPosition
 	l.readPosition += 1
+	l.column++
+}
+
+// pos returns the source position of the current character.
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
 }
 
 func (l *Lexer) Ch() byte {
 	return l.ch
 }
 
+// Dialect returns the dialect the lexer was created for.
+func (l *Lexer) Dialect() dialect.Dialect {
+	return l.dialect
+}
+
 func (l *Lexer) peekChar() byte {
 	if l.readPosition >= len(l.input) {
 		return 0
@@ -50,6 +99,72 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
+// skipWhitespaceAndComments skips everything up to the next token.
+// Comments are written { ... } or (* ... *), and // ... to the end of the
+// line when the dialect allows it.
+func (l *Lexer) skipWhitespaceAndComments() {
+	for {
+		l.skipWhitespace()
+		switch {
+		case l.ch == '{':
+			l.skipBlockComment("{", "}")
+		case l.ch == '(' && l.peekChar() == '*':
+			l.skipBlockComment("(*", "*)")
+		case l.ch == '/' && l.peekChar() == '/' && l.dialect.LineComments:
+			for l.ch != '\n' && l.ch != 0 {
+				l.readChar()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// skipBlockComment skips a comment starting at the current character. With
+// nested comments enabled, each further open delimiter must be matched by
+// its own close delimiter.
+func (l *Lexer) skipBlockComment(open, close string) {
+	start := l.pos()
+	l.advance(len(open))
+
+	depth := 1
+	for depth > 0 {
+		switch {
+		case l.ch == 0:
+			l.errors = append(l.errors, &Error{
+				Msg:    "Unterminated comment",
+				Detail: fmt.Sprintf("The comment starting with %q is never closed with %q.", open, close),
+				Hint:   fmt.Sprintf("Add %q where the comment should end.", close),
+				Pos:    start,
+				End:    token.Position{Filename: start.Filename, Offset: start.Offset + len(open), Line: start.Line, Column: start.Column + len(open)},
+			})
+			return
+		case l.lookingAt(close):
+			l.advance(len(close))
+			depth--
+		case l.dialect.NestedComments && l.lookingAt(open):
+			l.advance(len(open))
+			depth++
+		default:
+			l.readChar()
+		}
+	}
+}
+
+// lookingAt reports whether the input at the current character starts with s.
+func (l *Lexer) lookingAt(s string) bool {
+	if l.position >= len(l.input) {
+		return false
+	}
+	return strings.HasPrefix(l.input[l.position:], s)
+}
+
+func (l *Lexer) advance(n int) {
+	for range n {
+		l.readChar()
+	}
+}
+
 func isLetter(ch byte) bool {
 	return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z')
 }
@@ -66,26 +181,152 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an unsigned integer such as 42, or an unsigned real with
+// a fraction, an exponent or both, such as 3.14, 1e-5 or 2.5E+3. A '.' is
+// only part of the number if a digit follows it, so that '1..5' is a range.
+func (l *Lexer) readNumber() token.Token {
 	start := l.position
+	l.skipDigits()
+	var typ token.TokenType = token.INT
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		l.readChar()
+		l.skipDigits()
+		typ = token.REAL
+	}
+	if l.ch == 'e' || l.ch == 'E' {
+		// Only take the 'e' if an exponent follows, so that '1e' lexes as
+		// the number 1 and the identifier e.
+		next := l.readPosition
+		if next < len(l.input) && (l.input[next] == '+' || l.input[next] == '-') {
+			next++
+		}
+		if next < len(l.input) && isDigit(l.input[next]) {
+			l.advance(next - l.position)
+			l.skipDigits()
+			typ = token.REAL
+		}
+	}
+	return token.Token{Type: typ, Literal: l.input[start:l.position]}
+}
+
+func (l *Lexer) skipDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[start:l.position]
 }
 
+// readString reads a string literal: one or more pieces enclosed in single
+// quotes, in which a quote character is written as two quotes, and, where
+// the dialect allows it, character codes such as #13, written together without
+// spaces, e.g. 'one'#13#10'two'. A quoted piece may not span lines.
+func (l *Lexer) readString() token.Token {
+	var b strings.Builder
+	for {
+		switch {
+		case l.ch == '\'':
+			l.readQuoted(&b)
+		case l.ch == '#' && isDigit(l.peekChar()):
+			l.readCharCode(&b)
+		default:
+			return token.Token{Type: token.STRING, Literal: b.String()}
+		}
+	}
+}
+
+// readQuoted reads a quoted piece of a string literal into b.
+func (l *Lexer) readQuoted(b *strings.Builder) {
+	start := l.pos()
+	l.readChar()
+	for {
+		switch {
+		case l.ch == '\'' && l.peekChar() == '\'':
+			b.WriteByte('\'')
+			l.advance(2)
+		case l.ch == '\'':
+			l.readChar()
+			return
+		case l.ch == '\n' || l.ch == '\r' || l.ch == 0:
+			l.errors = append(l.errors, &Error{
+				Msg:    "Unterminated string",
+				Detail: "The string is not closed with a quote before the end of the line.",
+				Hint:   "Add a closing quote; to put a quote inside a string, write it twice, e.g. 'don''t'.",
+				Pos:    start,
+				End:    l.pos(),
+			})
+			return
+		default:
+			b.WriteByte(l.ch)
+			l.readChar()
+		}
+	}
+}
+
+// readCharCode reads a character code, '#' followed by the ordinal number
+// of a character, e.g. #10 for a line feed, into b.
+func (l *Lexer) readCharCode(b *strings.Builder) {
+	start := l.pos()
+	l.readChar()
+	digits := l.position
+	l.skipDigits()
+	n, err := strconv.Atoi(l.input[digits:l.position])
+
+	switch {
+	case !l.dialect.CharCodes:
+		l.errors = append(l.errors, &Error{
+			Msg:    fmt.Sprintf("Character codes are not allowed in the %s dialect", l.dialect.Name),
+			Detail: "Writing a character as '#' and its number is a Turbo Pascal extension.",
+			Hint:   "Use chr instead, e.g. 'chr(10)' for #10.",
+			Pos:    start,
+			End:    l.pos(),
+		})
+	case err != nil || n > 255:
+		l.errors = append(l.errors, &Error{
+			Msg:    "Character code out of range",
+			Detail: fmt.Sprintf("#%s is not a character; codes run from #0 to #255.", l.input[digits:l.position]),
+			Hint:   "Check the number after '#'.",
+			Pos:    start,
+			End:    l.pos(),
+		})
+	default:
+		b.WriteByte(byte(n))
+	}
+}
+
+// NextToken scans the next token and records where it starts and ends.
 func (l *Lexer) NextToken() token.Token {
-	l.skipWhitespace()
+	l.skipWhitespaceAndComments()
+	start := l.pos()
+	tok := l.scanToken()
+	tok.Pos = start
+	tok.End = l.pos()
+	if tok.Type == token.EOF {
+		tok.End = start
+	}
+	return tok
+}
+
+func (l *Lexer) scanToken() token.Token {
 	// FIRST: letters (identifiers and keywords)
 	if isLetter(l.ch) {
 		literal := l.readIdentifier()
 		tokType := token.LookupIdent(literal)
+		// 'otherwise' is only reserved in dialects whose case statements
+		// can have an else arm; ISO programs may use it as a name.
+		if tokType == token.OTHERWISE && !l.dialect.CaseElse {
+			tokType = token.IDENT
+		}
 		return token.Token{Type: tokType, Literal: literal}
 	}
 
 	// SECOND: numbers
 	if isDigit(l.ch) {
-		return token.Token{Type: token.INT, Literal: l.readNumber()}
+		return l.readNumber()
+	}
+
+	// THIRD: quoted strings and character codes
+	if l.ch == '\'' || (l.ch == '#' && isDigit(l.peekChar())) {
+		return l.readString()
 	}
 
 	var tok token.Token
@@ -101,6 +342,8 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
+	case ',':
+		tok = newToken(token.COMMA, l.ch)
 	case '+':
 		tok = newToken(token.PLUS, l.ch)
 	case '-':
@@ -113,8 +356,39 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
+	case '^':
+		tok = newToken(token.CARET, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
 	case '.':
-		tok = newToken(token.DOT, l.ch)
+		if l.peekChar() == '.' {
+			l.readChar()
+			tok = token.Token{Type: token.DOTDOT, Literal: ".."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
+	case '=':
+		tok = newToken(token.EQUAL, l.ch)
+	case '<':
+		switch l.peekChar() {
+		case '=':
+			l.readChar()
+			tok = token.Token{Type: token.LE, Literal: "<="}
+		case '>':
+			l.readChar()
+			tok = token.Token{Type: token.NEQ, Literal: "<>"}
+		default:
+			tok = newToken(token.LT, l.ch)
+		}
+	case '>':
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = token.Token{Type: token.GE, Literal: ">="}
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -0,0 +1,177 @@
+package lexer_test
+
+import (
+	"testing"
+
+	"pastel/dialect"
+	"pastel/lexer"
+	"pastel/token"
+)
+
+// scan returns the tokens of input up to the end of the input, and the
+// messages of the lexical errors found.
+func scan(d dialect.Dialect, input string) ([]token.Token, []string) {
+	l := lexer.NewFile("t.pas", input, d)
+	var toks []token.Token
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			break
+		}
+		toks = append(toks, tok)
+	}
+	var errs []string
+	for _, err := range l.Errors() {
+		errs = append(errs, err.Msg)
+	}
+	return toks, errs
+}
+
+func TestTokens(t *testing.T) {
+	type tok struct {
+		typ     token.TokenType
+		literal string
+	}
+	tests := []struct {
+		name    string
+		dialect dialect.Dialect
+		input   string
+		want    []tok
+	}{
+		{
+			name:    "numbers",
+			dialect: dialect.Pastel,
+			input:   "42 3.14 1e-5 2.5E+3",
+			want:    []tok{{token.INT, "42"}, {token.REAL, "3.14"}, {token.REAL, "1e-5"}, {token.REAL, "2.5E+3"}},
+		},
+		{
+			name:    "range is not a real",
+			dialect: dialect.Pastel,
+			input:   "1..5",
+			want:    []tok{{token.INT, "1"}, {token.DOTDOT, ".."}, {token.INT, "5"}},
+		},
+		{
+			name:    "e without an exponent is a name",
+			dialect: dialect.Pastel,
+			input:   "1e",
+			want:    []tok{{token.INT, "1"}, {token.IDENT, "e"}},
+		},
+		{
+			name:    "quote written twice",
+			dialect: dialect.ISO,
+			input:   "'don''t'",
+			want:    []tok{{token.STRING, "don't"}},
+		},
+		{
+			name:    "character codes",
+			dialect: dialect.Turbo,
+			input:   "'a'#13#10'b'",
+			want:    []tok{{token.STRING, "a\r\nb"}},
+		},
+		{
+			name:    "comments",
+			dialect: dialect.Pastel,
+			input:   "{ a } x (* b *) y // c\nz",
+			want:    []tok{{token.IDENT, "x"}, {token.IDENT, "y"}, {token.IDENT, "z"}},
+		},
+		{
+			name:    "no line comments in iso",
+			dialect: dialect.ISO,
+			input:   "x // y",
+			want:    []tok{{token.IDENT, "x"}, {token.SLASH, "/"}, {token.SLASH, "/"}, {token.IDENT, "y"}},
+		},
+		{
+			name:    "nested comments in fpc",
+			dialect: dialect.FPC,
+			input:   "{ a { b } c } x",
+			want:    []tok{{token.IDENT, "x"}},
+		},
+		{
+			name:    "comment ends at the first brace in turbo",
+			dialect: dialect.Turbo,
+			input:   "{ a { b } c } x",
+			want:    []tok{{token.IDENT, "c"}, {token.ILLEGAL, "}"}, {token.IDENT, "x"}},
+		},
+		{
+			name:    "otherwise is reserved with case else arms",
+			dialect: dialect.Pastel,
+			input:   "otherwise",
+			want:    []tok{{token.OTHERWISE, "otherwise"}},
+		},
+		{
+			name:    "otherwise is a name in iso",
+			dialect: dialect.ISO,
+			input:   "otherwise",
+			want:    []tok{{token.IDENT, "otherwise"}},
+		},
+		{
+			name:    "predeclared names are not keywords",
+			dialect: dialect.Pastel,
+			input:   "true boolean read write",
+			want:    []tok{{token.IDENT, "true"}, {token.IDENT, "boolean"}, {token.IDENT, "read"}, {token.IDENT, "write"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toks, errs := scan(tt.dialect, tt.input)
+			if len(errs) > 0 {
+				t.Fatalf("errors %q", errs)
+			}
+			var got []tok
+			for _, tk := range toks {
+				got = append(got, tok{tk.Type, tk.Literal})
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("token %d is %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect dialect.Dialect
+		input   string
+		want    string
+	}{
+		{name: "unterminated brace comment", dialect: dialect.Pastel, input: "x { y", want: "Unterminated comment"},
+		{name: "unterminated paren comment", dialect: dialect.Pastel, input: "x (* y", want: "Unterminated comment"},
+		{name: "unterminated nested comment", dialect: dialect.FPC, input: "{ a { b }", want: "Unterminated comment"},
+		{name: "unterminated string", dialect: dialect.Pastel, input: "'abc\n'", want: "Unterminated string"},
+		{name: "character code in iso", dialect: dialect.ISO, input: "#10", want: "Character codes are not allowed in the iso dialect"},
+		{name: "character code out of range", dialect: dialect.Turbo, input: "#256", want: "Character code out of range"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := scan(tt.dialect, tt.input)
+			if len(errs) == 0 || errs[0] != tt.want {
+				t.Errorf("errors %q, want %q first", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestPositions(t *testing.T) {
+	toks, _ := scan(dialect.Pastel, "x :=\n  10")
+	want := []struct{ pos, end token.Position }{
+		{token.Position{Filename: "t.pas", Offset: 0, Line: 1, Column: 1}, token.Position{Filename: "t.pas", Offset: 1, Line: 1, Column: 2}},
+		{token.Position{Filename: "t.pas", Offset: 2, Line: 1, Column: 3}, token.Position{Filename: "t.pas", Offset: 4, Line: 1, Column: 5}},
+		{token.Position{Filename: "t.pas", Offset: 7, Line: 2, Column: 3}, token.Position{Filename: "t.pas", Offset: 9, Line: 2, Column: 5}},
+	}
+	if len(toks) != len(want) {
+		t.Fatalf("got %d tokens, want %d", len(toks), len(want))
+	}
+	for i, tok := range toks {
+		if tok.Pos != want[i].pos || tok.End != want[i].end {
+			t.Errorf("%q at %v-%v, want %v-%v", tok.Literal, tok.Pos, tok.End, want[i].pos, want[i].end)
+		}
+	}
+}
@@ -1,18 +1,31 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"pastel/diagnostics"
+	"pastel/dialect"
 	"pastel/interpreter"
 	"pastel/lexer"
 	"pastel/parser"
 )
 
 func main() {
-	var input string
+	dialectName := flag.String("dialect", dialect.Pastel.Name, "language dialect: pastel, iso, turbo or fpc")
+	leaks := flag.Bool("leaks", false, "report variables allocated with new that were never disposed")
+	flag.Parse()
 
-	if len(os.Args) > 1 {
-		filename := os.Args[1]
+	d, ok := dialect.Lookup(*dialectName)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown dialect %q\n", *dialectName)
+		os.Exit(2)
+	}
+
+	var input, filename string
+
+	if flag.NArg() > 0 {
+		filename = flag.Arg(0)
 		data, err := os.ReadFile(filename)
 
 		if err != nil {
@@ -25,31 +38,49 @@ func main() {
 	}
 
 	// Step 1: Lexical analysis
-	l := lexer.New(input)
+	l := lexer.NewFile(filename, input, d)
 
 	// Step 2: Parsing
 	p := parser.New(l)
 	prog := p.ParseProgram()
 
+	// Errors are rendered against the source so they can show the offending line
+	report := diagnostics.NewRenderer(input, diagnostics.UseColor(os.Stdout))
+
 	// Step 3: Check for parsing errors
 	if p.HasErrors() {
 		fmt.Println("Parsing errors encountered:")
 		for _, err := range p.Errors() {
-			fmt.Println(err.Error())
+			fmt.Println()
+			fmt.Println(report.Render(err.Diagnostic()))
 		}
 		return
 	}
 
 	// Step 4: Create a new environment for interpretation
 	env := interpreter.NewEnviroment()
+	env.SetDialect(d)
 
 	// Step 5: Interpret the program
 	if err := interpreter.EvalProgram(prog, env); err != nil {
 		fmt.Println("Runtime error encountered:")
-		fmt.Println(err.Error())
+		if r, ok := err.(diagnostics.Reporter); ok {
+			fmt.Println()
+			fmt.Println(report.Render(r.Diagnostic()))
+		} else {
+			fmt.Println(err.Error())
+		}
 		return
 	}
 
 	// Step 6: Successful execution
 	fmt.Println("Program executed successfully.")
+
+	// Step 7: Report heap variables the program did not dispose
+	if *leaks {
+		for _, leak := range env.Leaks() {
+			fmt.Println()
+			fmt.Println(report.Render(leak.Diagnostic()))
+		}
+	}
 }
@@ -1,27 +1,199 @@
 package parser
 
-type Stmt any
+import "pastel/token"
 
+// Node is implemented by every AST node.
+type Node interface {
+	Pos() token.Position // position of the first character of the node
+	End() token.Position // position immediately after the node
+}
+
+// Span records the source range a node was parsed from. It is embedded in
+// every AST node.
+type Span struct {
+	StartPos token.Position
+	EndPos   token.Position
+}
+
+func (s Span) Pos() token.Position { return s.StartPos }
+func (s Span) End() token.Position { return s.EndPos }
+
+type Stmt interface {
+	Node
+}
+
+// AssignStmt is 'Target := Value'. Target is a variable access: an
+// Identifier, or an IndexExpr or FieldExpr selecting a component of a
+// variable.
 type AssignStmt struct {
-	Name  string
-	Value Expr
+	Span
+	Target Expr
+	Value  Expr
 }
 
+// PrintStmt is 'write(Args)' or 'writeln(Args)'. File is the text or file
+// variable written to, or nil for the standard output.
 type PrintStmt struct {
-	Argument Expr
+	Span
+	Newline bool
+	File    Expr
+	Args    []*WriteArg
+}
+
+// WriteArg is an argument of write or writeln: Value, optionally followed
+// by ':' Width and then ':' Places, the number of decimal places of a real,
+// e.g. 'x:8' or 'r:10:3'.
+type WriteArg struct {
+	Span
+	Value  Expr
+	Width  Expr // nil if not given
+	Places Expr // nil if not given
+}
+
+// ReadStmt is 'read(Args)' or 'readln(Args)', where each argument is a
+// variable to read into. File is the file read from.
+type ReadStmt struct {
+	Span
+	Newline bool
+	File    Expr
+	Args    []Expr
+}
+
+// IfStmt is 'if Condition then Then else Else'. Else is nil when there is no
+// else part, and either branch may be nil for an empty statement.
+type IfStmt struct {
+	Span
+	Condition Expr
+	Then      Stmt
+	Else      Stmt
+}
+
+type WhileStmt struct {
+	Span
+	Condition Expr
+	Body      Stmt
+}
+
+// RepeatStmt is 'repeat Body until Condition'. Unlike while, its body is a
+// statement sequence and always runs at least once.
+type RepeatStmt struct {
+	Span
+	Body      []Stmt
+	Condition Expr
+}
+
+// ForStmt is 'for Variable := Initial to|downto Final do Body'.
+type ForStmt struct {
+	Span
+	Variable *Identifier
+	Initial  Expr
+	Final    Expr
+	Downto   bool
+	Body     Stmt
+}
+
+// CaseStmt is 'case Selector of arms end'. HasElse is set when the statement
+// ends with an 'else' or 'otherwise' arm, whose statements are in Else.
+type CaseStmt struct {
+	Span
+	Selector Expr
+	Arms     []*CaseArm
+	Else     []Stmt
+	HasElse  bool
+}
+
+// CaseArm is one 'labels: statement' arm of a case statement.
+type CaseArm struct {
+	Span
+	Labels []*CaseLabel
+	Body   Stmt
+}
+
+// CaseLabel is a single case constant, or the range Low..High when High is
+// not nil.
+type CaseLabel struct {
+	Span
+	Low  Expr
+	High Expr
 }
 
 type Program struct {
+	Span
 	Name         string
+	Params       []*Identifier
 	Declarations []Stmt
 	Main         *CompoundStmt
 }
 
 type CompoundStmt struct {
+	Span
 	Statements []Stmt
 }
 
 type VarDecl struct {
+	Span
+	Name string
+	Type Type
+}
+
+// WithStmt is 'with Records do Body'. The fields of each record variable
+// can be named directly in Body; with several records, the fields of later
+// ones hide those of earlier ones.
+type WithStmt struct {
+	Span
+	Records []Expr
+	Body    Stmt
+}
+
+// RoutineDecl declares a procedure or, when IsFunction is set, a function.
+// A forward declaration has Forward set and no body; the routine is then
+// declared again, with its body, later in the same block.
+type RoutineDecl struct {
+	Span
+	Name         string
+	NameSpan     Span
+	IsFunction   bool
+	Params       []*Param
+	ResultType   Type // functions only
+	Forward      bool
+	Declarations []Stmt
+	Body         *CompoundStmt
+}
+
+// Kind returns "procedure" or "function", for messages.
+func (d *RoutineDecl) Kind() string {
+	if d.IsFunction {
+		return "function"
+	}
+	return "procedure"
+}
+
+// Param is a formal parameter of a procedure or function. A var parameter
+// is passed by reference. A procedural or functional parameter has no Type;
+// Routine holds its heading instead.
+type Param struct {
+	Span
+	Name    string
+	Type    Type
+	Var     bool
+	Routine *RoutineDecl
+}
+
+// Kind describes the parameter for messages, e.g. "var parameter".
+func (p *Param) Kind() string {
+	switch {
+	case p.Routine != nil:
+		return p.Routine.Kind() + " parameter"
+	case p.Var:
+		return "var parameter"
+	default:
+		return "parameter"
+	}
+}
+
+// CallStmt is a procedure call statement, e.g. 'swap(a, b)'.
+type CallStmt struct {
+	Span
 	Name string
-	Type string
+	Args []Expr
 }
@@ -0,0 +1,487 @@
+package parser
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"pastel/diagnostics"
+	"pastel/token"
+	"strings"
+)
+
+// ConstDecl declares a named constant. Value is the constant's value,
+// already folded to a literal.
+type ConstDecl struct {
+	Span
+	Name  string
+	Value Expr
+}
+
+// Universe holds the predeclared constants, which every program can use
+// as though they were declared in a block enclosing it.
+var Universe = []*ConstDecl{
+	{Name: "maxint", Value: &IntegerLiteral{Value: math.MaxInt}},
+	{Name: "true", Value: &BooleanLiteral{Value: true}},
+	{Name: "false", Value: &BooleanLiteral{Value: false}},
+}
+
+// scope records the names declared by one block, each mapped to its
+// declaration. Every name hides declarations of the same name in
+// enclosing blocks.
+type scope map[string]Node
+
+func (p *Parser) pushScope() {
+	p.scopes = append(p.scopes, scope{})
+}
+
+func (p *Parser) popScope() {
+	p.scopes = p.scopes[:len(p.scopes)-1]
+}
+
+// declare records that the innermost block declares name, with the
+// declaration decl.
+func (p *Parser) declare(name string, decl Node) {
+	p.scopes[len(p.scopes)-1][name] = decl
+}
+
+// lookup returns the declaration name refers to in the current block, or
+// nil if it is not declared.
+func (p *Parser) lookup(name string) Node {
+	for i := len(p.scopes) - 1; i >= 0; i-- {
+		if decl, ok := p.scopes[i][name]; ok {
+			return decl
+		}
+	}
+	return nil
+}
+
+// lookupConst returns the constant that name refers to, or nil if name is
+// not a constant in the current block.
+func (p *Parser) lookupConst(name string) *ConstDecl {
+	decl, _ := p.lookup(name).(*ConstDecl)
+	return decl
+}
+
+// parseConstSection parses 'const' followed by one or more definitions of
+// the form 'name = constant-expression ;'. Each expression is folded to its
+// value here, so later constants and case labels can use it.
+func (p *Parser) parseConstSection() []Stmt {
+	// Advance to the next token after 'const'
+	p.nextToken()
+
+	if !p.curTokenIs(token.IDENT) {
+		p.addError(&ParserError{
+			Msg:    "Expected constant name after 'const'",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "Constant definitions are written 'name = value;'.",
+		})
+		return nil
+	}
+
+	var decls []Stmt
+	for p.curTokenIs(token.IDENT) {
+		start := p.curToken.Pos
+		name := p.curToken.Literal
+
+		if !p.expectPeek(token.EQUAL) {
+			return decls
+		}
+
+		// Advance to the next token after '='
+		p.nextToken()
+
+		value, ok := p.foldConstant(p.ParseExpression())
+
+		if !p.curTokenIs(token.SEMICOLON) {
+			p.addError(&ParserError{
+				Msg:    "Expected ';' after constant definition",
+				Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+				Hint:   "Constant definitions must end with a semicolon.",
+			})
+			return decls
+		}
+
+		// Advance to the next token after the semicolon
+		p.nextToken()
+
+		if ok {
+			decl := &ConstDecl{Span: p.spanFrom(start), Name: name, Value: value}
+			p.declare(name, decl)
+			decls = append(decls, decl)
+		}
+	}
+
+	return decls
+}
+
+// foldConstant evaluates a constant expression to a literal carrying the
+// expression's span. It reports an error if expr refers to anything but
+// literals and constants.
+func (p *Parser) foldConstant(expr Expr) (Expr, bool) {
+	switch e := expr.(type) {
+	case nil:
+		// Already reported while parsing the expression.
+		return nil, false
+
+	case *IntegerLiteral, *RealLiteral, *BooleanLiteral, *EnumLiteral, *StringLiteral:
+		return e, true
+
+	case *Identifier:
+		decl := p.lookupConst(e.Value)
+		if decl == nil {
+			p.addError(&ParserError{
+				Msg:    fmt.Sprintf("'%s' is not a constant", e.Value),
+				Detail: "Only literals and names declared with 'const' can be used in a constant expression.",
+				Hint:   "Declare the value with 'const' first, or write it out.",
+				Pos:    e.Pos(),
+				End:    e.End(),
+			})
+			return nil, false
+		}
+		return withSpan(decl.Value, e.Span), true
+
+	case *UnaryExpr:
+		operand, ok := p.foldConstant(e.Operand)
+		if !ok {
+			return nil, false
+		}
+		switch o := operand.(type) {
+		case *BooleanLiteral:
+			if e.Operator.Type == token.NOT {
+				return &BooleanLiteral{Span: e.Span, Value: !o.Value}, true
+			}
+		case *IntegerLiteral:
+			switch e.Operator.Type {
+			case token.PLUS:
+				return &IntegerLiteral{Span: e.Span, Value: o.Value}, true
+			case token.MINUS:
+				v, ok := IntegerOp(token.MINUS, 0, o.Value)
+				if !ok {
+					return nil, p.integerOverflow(e)
+				}
+				return &IntegerLiteral{Span: e.Span, Value: v}, true
+			}
+		case *RealLiteral:
+			switch e.Operator.Type {
+			case token.PLUS:
+				return &RealLiteral{Span: e.Span, Value: o.Value}, true
+			case token.MINUS:
+				return &RealLiteral{Span: e.Span, Value: -o.Value}, true
+			}
+		}
+		return nil, p.constOperandError(e.Operator, e)
+
+	case *BinaryExpr:
+		left, ok := p.foldConstant(e.Left)
+		if !ok {
+			return nil, false
+		}
+		right, ok := p.foldConstant(e.Right)
+		if !ok {
+			return nil, false
+		}
+		return p.foldBinary(e, left, right)
+
+	default:
+		p.addError(&ParserError{
+			Msg:    "Expected a constant expression",
+			Detail: "This expression can only be evaluated when the program runs.",
+			Hint:   "Constant expressions may only use literals, constants and operators.",
+			Pos:    expr.Pos(),
+			End:    expr.End(),
+		})
+		return nil, false
+	}
+}
+
+func (p *Parser) foldBinary(e *BinaryExpr, left, right Expr) (Expr, bool) {
+	if l, r, ok := realOperands(left, right); ok {
+		return p.foldReal(e, l, r)
+	}
+
+	switch l := left.(type) {
+	case *IntegerLiteral:
+		r, ok := right.(*IntegerLiteral)
+		if !ok {
+			break
+		}
+		switch e.Operator.Type {
+		case token.PLUS, token.MINUS, token.STAR:
+			v, ok := IntegerOp(e.Operator.Type, l.Value, r.Value)
+			if !ok {
+				return nil, p.integerOverflow(e)
+			}
+			return &IntegerLiteral{Span: e.Span, Value: v}, true
+		case token.SLASH:
+			return p.foldReal(e, float64(l.Value), float64(r.Value))
+		case token.DIV, token.MOD:
+			return p.foldDivision(e, l.Value, r.Value)
+		}
+		if isRelational(e.Operator.Type) {
+			return &BooleanLiteral{Span: e.Span, Value: compareInts(e.Operator.Type, l.Value, r.Value)}, true
+		}
+
+	case *BooleanLiteral:
+		r, ok := right.(*BooleanLiteral)
+		if !ok {
+			break
+		}
+		switch e.Operator.Type {
+		case token.AND:
+			return &BooleanLiteral{Span: e.Span, Value: l.Value && r.Value}, true
+		case token.OR:
+			return &BooleanLiteral{Span: e.Span, Value: l.Value || r.Value}, true
+		}
+		if isRelational(e.Operator.Type) {
+			return &BooleanLiteral{Span: e.Span, Value: compareInts(e.Operator.Type, boolOrd(l.Value), boolOrd(r.Value))}, true
+		}
+
+	case *EnumLiteral:
+		r, ok := right.(*EnumLiteral)
+		if ok && r.Type == l.Type && isRelational(e.Operator.Type) {
+			return &BooleanLiteral{Span: e.Span, Value: compareInts(e.Operator.Type, l.Ord, r.Ord)}, true
+		}
+
+	case *StringLiteral:
+		r, ok := right.(*StringLiteral)
+		if !ok {
+			break
+		}
+		if e.Operator.Type == token.PLUS {
+			return &StringLiteral{Span: e.Span, Value: l.Value + r.Value}, true
+		}
+		if isRelational(e.Operator.Type) && e.Operator.Type != token.IN {
+			return &BooleanLiteral{Span: e.Span, Value: compareInts(e.Operator.Type, strings.Compare(l.Value, r.Value), 0)}, true
+		}
+	}
+
+	return nil, p.constOperandError(e.Operator, e)
+}
+
+// foldDivision folds 'l div r' or 'l mod r', with the same rules for 'mod'
+// as the interpreter applies in the parser's dialect.
+func (p *Parser) foldDivision(e *BinaryExpr, l, r int) (Expr, bool) {
+	if r == 0 {
+		return nil, p.divisionByZero(e)
+	}
+	if e.Operator.Type == token.DIV {
+		return &IntegerLiteral{Span: e.Span, Value: l / r}, true
+	}
+	if p.l.Dialect().TruncatedMod {
+		return &IntegerLiteral{Span: e.Span, Value: l % r}, true
+	}
+	if r < 0 {
+		p.addError(&ParserError{
+			Msg:    "Negative modulus in constant expression",
+			Detail: fmt.Sprintf("The right operand of 'mod' must be positive, but it is %d.", r),
+			Hint:   "Take the modulus by the absolute value of the divisor.",
+			Pos:    e.Right.Pos(),
+			End:    e.Right.End(),
+		})
+		return nil, false
+	}
+	m := l % r
+	if m < 0 {
+		m += r
+	}
+	return &IntegerLiteral{Span: e.Span, Value: m}, true
+}
+
+func (p *Parser) divisionByZero(e *BinaryExpr) bool {
+	p.addError(&ParserError{
+		Msg:    "Division by zero in constant expression",
+		Detail: "The divisor of this constant expression is zero.",
+		Hint:   "Ensure the divisor is not zero.",
+		Pos:    e.Right.Pos(),
+		End:    e.Right.End(),
+	})
+	return false
+}
+
+// realOperands returns the operands of a binary operator as reals if one is
+// a real and the other a real or an integer, which is widened.
+func realOperands(left, right Expr) (l, r float64, ok bool) {
+	_, leftReal := left.(*RealLiteral)
+	_, rightReal := right.(*RealLiteral)
+	if !leftReal && !rightReal {
+		return 0, 0, false
+	}
+	l, okl := numericValue(left)
+	r, okr := numericValue(right)
+	return l, r, okl && okr
+}
+
+func numericValue(lit Expr) (float64, bool) {
+	switch l := lit.(type) {
+	case *IntegerLiteral:
+		return float64(l.Value), true
+	case *RealLiteral:
+		return l.Value, true
+	default:
+		return 0, false
+	}
+}
+
+// foldReal applies an arithmetic or relational operator to the real
+// operands of e.
+func (p *Parser) foldReal(e *BinaryExpr, l, r float64) (Expr, bool) {
+	var v float64
+	switch e.Operator.Type {
+	case token.PLUS:
+		v = l + r
+	case token.MINUS:
+		v = l - r
+	case token.STAR:
+		v = l * r
+	case token.SLASH:
+		if r == 0 {
+			return nil, p.divisionByZero(e)
+		}
+		v = l / r
+	default:
+		if isRelational(e.Operator.Type) && e.Operator.Type != token.IN {
+			return &BooleanLiteral{Span: e.Span, Value: compareInts(e.Operator.Type, cmp.Compare(l, r), 0)}, true
+		}
+		return nil, p.constOperandError(e.Operator, e)
+	}
+	if math.IsInf(v, 0) {
+		p.addError(&ParserError{
+			Msg:    "Real overflow in constant expression",
+			Detail: "The value of this constant expression is too large to be represented as a real.",
+			Hint:   "Use smaller operands.",
+			Pos:    e.Pos(),
+			End:    e.End(),
+		})
+		return nil, false
+	}
+	return &RealLiteral{Span: e.Span, Value: v}, true
+}
+
+func (p *Parser) constOperandError(op token.Token, expr Expr) bool {
+	p.addError(&ParserError{
+		Msg:    "Type mismatch in constant expression",
+		Detail: fmt.Sprintf("Operator '%s' cannot be applied to these operands.", op.Literal),
+		Hint:   "Arithmetic needs integer or real operands; 'and', 'or' and 'not' need boolean ones.",
+		Pos:    op.Pos,
+		End:    op.End,
+		Labels: []diagnostics.Label{{Pos: expr.Pos(), End: expr.End(), Msg: "in this expression"}},
+	})
+	return false
+}
+
+// integerOverflow reports that the integer constant expression e has a
+// value outside the range of integer.
+func (p *Parser) integerOverflow(e Expr) bool {
+	p.addError(&ParserError{
+		Msg:    "Integer overflow in constant expression",
+		Detail: "The value of this constant expression lies outside -maxint..maxint, the range of integer.",
+		Hint:   "Use smaller operands, or real operands for values this large.",
+		Pos:    e.Pos(),
+		End:    e.End(),
+	})
+	return false
+}
+
+// IntegerOp applies op, which is '+', '-' or '*', to the integers l and r.
+// ok is false if the result lies outside -maxint..maxint, the range of
+// integer, in which case v is not meaningful.
+func IntegerOp(op token.TokenType, l, r int) (v int, ok bool) {
+	switch op {
+	case token.PLUS:
+		return l + r, r >= 0 && l <= math.MaxInt-r || r < 0 && l >= -math.MaxInt-r
+	case token.MINUS:
+		return l - r, r <= 0 && l <= math.MaxInt+r || r > 0 && l >= -math.MaxInt+r
+	case token.STAR:
+		if l == 0 || r == 0 {
+			return 0, true
+		}
+		v = l * r
+		return v, v/r == l && v != math.MinInt
+	default:
+		panic("IntegerOp: not an integer operator")
+	}
+}
+
+func compareInts(op token.TokenType, l, r int) bool {
+	switch op {
+	case token.EQUAL:
+		return l == r
+	case token.NEQ:
+		return l != r
+	case token.LT:
+		return l < r
+	case token.LE:
+		return l <= r
+	case token.GT:
+		return l > r
+	default:
+		return l >= r
+	}
+}
+
+func boolOrd(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// withSpan returns a copy of the literal lit located at span.
+func withSpan(lit Expr, span Span) Expr {
+	switch l := lit.(type) {
+	case *IntegerLiteral:
+		return &IntegerLiteral{Span: span, Value: l.Value}
+	case *RealLiteral:
+		return &RealLiteral{Span: span, Value: l.Value}
+	case *StringLiteral:
+		return &StringLiteral{Span: span, Value: l.Value}
+	case *BooleanLiteral:
+		return &BooleanLiteral{Span: span, Value: l.Value}
+	case *EnumLiteral:
+		return &EnumLiteral{Span: span, Type: l.Type, Ord: l.Ord}
+	default:
+		return lit
+	}
+}
+
+// constOrdinal returns the ordinal value of a constant expression, reporting
+// an error if expr is not a constant of an ordinal type.
+func (p *Parser) constOrdinal(expr Expr) (int, bool) {
+	value, ok := p.foldConstant(expr)
+	if !ok {
+		return 0, false
+	}
+	if t := literalType(value); !IsOrdinal(t) {
+		p.addError(&ParserError{
+			Msg:    "Expected a constant of an ordinal type",
+			Detail: fmt.Sprintf("The constant is %s.", t),
+			Hint:   "Use an integer, boolean, char or enumerated value.",
+			Pos:    expr.Pos(),
+			End:    expr.End(),
+		})
+		return 0, false
+	}
+	return literalOrdinal(value), true
+}
+
+// checkNotConstant reports an error if name, about to be assigned at the
+// current token, is a constant.
+func (p *Parser) checkNotConstant(name string) {
+	if decl := p.lookupConst(name); decl != nil {
+		p.addError(&ParserError{
+			Msg:    fmt.Sprintf("Cannot assign to constant '%s'", name),
+			Detail: "A constant keeps the value it was declared with for the whole program.",
+			Hint:   fmt.Sprintf("Declare '%s' with 'var' if it needs to change.", name),
+			Labels: constDeclaredHere(decl),
+		})
+	}
+}
+
+// constDeclaredHere labels the declaration of a user constant. Predeclared
+// constants have no position and get no label.
+func constDeclaredHere(decl *ConstDecl) []diagnostics.Label {
+	if !decl.Pos().IsValid() {
+		return nil
+	}
+	return []diagnostics.Label{{Pos: decl.Pos(), End: decl.End(), Msg: "constant declared here"}}
+}
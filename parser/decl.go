@@ -0,0 +1,532 @@
+package parser
+
+import (
+	"fmt"
+	"pastel/diagnostics"
+	"pastel/token"
+)
+
+// parseDeclarations parses the declaration part of a block: any number of
+// 'const', 'type' and 'var' sections and procedure and function
+// declarations, up to the 'begin' of the block's body. The names declared
+// are recorded in the innermost scope.
+func (p *Parser) parseDeclarations() []Stmt {
+	var decls []Stmt
+	forwards := map[string]*RoutineDecl{}
+
+	for {
+		switch p.curToken.Type {
+		case token.CONST:
+			decls = append(decls, p.parseConstSection()...)
+
+		case token.TYPE:
+			types := p.parseTypeSection()
+			p.resolvePointers()
+			decls = append(decls, p.takeEnumConsts()...)
+			decls = append(decls, types...)
+
+		case token.VAR:
+			vars := p.parseVarSection()
+			p.resolvePointers()
+			for _, decl := range vars {
+				p.declare(decl.(*VarDecl).Name, decl)
+			}
+			decls = append(decls, p.takeEnumConsts()...)
+			decls = append(decls, vars...)
+
+		case token.PROCEDURE, token.FUNCTION:
+			decl := p.parseRoutineDecl(forwards)
+			if decl == nil {
+				return decls
+			}
+			decls = append(decls, decl)
+
+		default:
+			for _, fwd := range forwards {
+				p.addError(&ParserError{
+					Msg:    fmt.Sprintf("Missing body for forward-declared %s '%s'", fwd.Kind(), fwd.Name),
+					Detail: fmt.Sprintf("'%s' was declared 'forward', but its body never follows in this block.", fwd.Name),
+					Hint:   fmt.Sprintf("Declare the %s again with its body before the 'begin' of the block.", fwd.Kind()),
+					Pos:    fwd.Pos(),
+					End:    fwd.End(),
+				})
+			}
+			return decls
+		}
+	}
+}
+
+// parseVarSection parses 'var' followed by one or more declarations of the
+// form 'name {, name} : type ;'. It returns one VarDecl per name.
+func (p *Parser) parseVarSection() []Stmt {
+	// Advance to the next token after 'var'
+	p.nextToken()
+
+	if p.curToken.Type != token.IDENT {
+		p.addError(&ParserError{
+			Msg:    "Expected variable name after 'var'",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "Variable declarations must start with a valid identifier.",
+		})
+		return nil
+	}
+
+	var decls []Stmt
+	for p.curTokenIs(token.IDENT) {
+		start := p.curToken.Pos
+		names := p.parseIdentList()
+
+		if p.curToken.Type != token.COLON {
+			p.addError(&ParserError{
+				Msg:    "Expected ':' after variable name",
+				Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+				Hint:   "Variable declarations must specify a type after the colon.",
+			})
+			return decls
+		}
+
+		// Advance to the next token after ':'
+		p.nextToken()
+
+		varType, ok := p.parseType()
+		if !ok {
+			// Carry on with the next declaration.
+			p.skipDeclaration()
+			if !p.curTokenIs(token.SEMICOLON) {
+				return decls
+			}
+			p.nextToken()
+			continue
+		}
+
+		if p.curToken.Type != token.SEMICOLON {
+			p.addError(&ParserError{
+				Msg:    "Expected ';' after variable declaration",
+				Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+				Hint:   "Variable declarations must end with a semicolon.",
+			})
+			return decls
+		}
+
+		// Advance to the next token after the semicolon
+		p.nextToken()
+
+		for _, name := range names {
+			decls = append(decls, &VarDecl{Span: Span{start, p.lastEnd}, Name: name.Value, Type: varType})
+		}
+	}
+
+	return decls
+}
+
+// skipDeclaration skips the rest of a declaration after an error in its
+// type, up to the ';' that ends it or the 'end' or ')' that ends the field
+// list it is in, so that parsing can carry on after it. Records and
+// bracketed lists that start along the way are skipped whole. It gives up
+// at a 'begin' or the end of the input.
+func (p *Parser) skipDeclaration() {
+	depth := 0
+	for {
+		switch p.curToken.Type {
+		case token.BEGIN, token.EOF:
+			return
+		case token.RECORD, token.LPAREN, token.LBRACKET:
+			depth++
+		case token.RBRACKET:
+			// The error may have come inside the brackets.
+			depth = max(depth-1, 0)
+		case token.SEMICOLON, token.END, token.RPAREN:
+			if depth == 0 {
+				return
+			}
+			if p.curToken.Type != token.SEMICOLON {
+				depth--
+			}
+		}
+		p.nextToken()
+	}
+}
+
+// takeEnumConsts returns the constants of the enumerated types parsed since
+// it was last called, so that they are declared along with their type.
+func (p *Parser) takeEnumConsts() []Stmt {
+	consts := p.enumConsts
+	p.enumConsts = nil
+	return consts
+}
+
+// parseIdentList parses 'name {, name}'.
+func (p *Parser) parseIdentList() []*Identifier {
+	var names []*Identifier
+	for {
+		if !p.curTokenIs(token.IDENT) {
+			p.addError(&ParserError{
+				Msg:    "Expected identifier",
+				Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+				Hint:   "Names in a list are separated by commas, e.g. 'a, b, c'.",
+			})
+			return names
+		}
+		names = append(names, &Identifier{Span: Span{p.curToken.Pos, p.curToken.End}, Value: p.curToken.Literal})
+		p.nextToken()
+
+		if !p.curTokenIs(token.COMMA) {
+			return names
+		}
+		p.nextToken()
+	}
+}
+
+// parseRoutineDecl parses a procedure or function declaration:
+//
+//	'procedure' name [params] ';' (block | 'forward') ';'
+//	'function' name [params] ':' type ';' (block | 'forward') ';'
+//
+// forwards holds the routines of the enclosing block that were declared
+// 'forward' and still wait for their body. As in ISO Pascal, the later
+// declaration may leave out the parameters and result type.
+func (p *Parser) parseRoutineDecl(forwards map[string]*RoutineDecl) *RoutineDecl {
+	start := p.curToken.Pos
+
+	decl := p.parseRoutineHeading(forwards)
+	if decl == nil {
+		return nil
+	}
+	fwd := forwards[decl.Name]
+	p.declare(decl.Name, decl)
+
+	if !p.curTokenIs(token.SEMICOLON) {
+		p.addError(&ParserError{
+			Msg:    fmt.Sprintf("Expected ';' after %s heading", decl.Kind()),
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   fmt.Sprintf("The heading of a %s ends with a semicolon.", decl.Kind()),
+		})
+		return nil
+	}
+	p.nextToken()
+
+	if p.curTokenIs(token.FORWARD) {
+		if fwd != nil {
+			p.addError(&ParserError{
+				Msg:    fmt.Sprintf("'%s' is already declared forward", decl.Name),
+				Detail: "A routine can only be declared 'forward' once.",
+				Hint:   "Remove the second forward declaration.",
+				Labels: []diagnostics.Label{{Pos: fwd.Pos(), End: fwd.End(), Msg: "first declared here"}},
+			})
+		}
+		decl.Forward = true
+		forwards[decl.Name] = decl
+		p.nextToken()
+	} else {
+		delete(forwards, decl.Name)
+		if !p.parseRoutineBlock(decl) {
+			return nil
+		}
+	}
+
+	if !p.curTokenIs(token.SEMICOLON) {
+		p.addError(&ParserError{
+			Msg:    fmt.Sprintf("Expected ';' after %s '%s'", decl.Kind(), decl.Name),
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "A procedure or function declaration ends with a semicolon after its 'end'.",
+		})
+		return nil
+	}
+	p.nextToken()
+
+	decl.Span = p.spanFrom(start)
+	return decl
+}
+
+// parseRoutineBlock parses the declarations and body of decl, in a scope of
+// their own that also holds the parameters.
+func (p *Parser) parseRoutineBlock(decl *RoutineDecl) bool {
+	p.pushScope()
+	defer p.popScope()
+
+	for _, param := range decl.Params {
+		p.declare(param.Name, param)
+	}
+
+	decl.Declarations = p.parseDeclarations()
+
+	if !p.curTokenIs(token.BEGIN) {
+		p.addError(&ParserError{
+			Msg:    fmt.Sprintf("Expected 'begin' for the body of %s '%s'", decl.Kind(), decl.Name),
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "A routine body is a 'begin' ... 'end' block after its declarations.",
+		})
+		return false
+	}
+	decl.Body = p.parseCompound().(*CompoundStmt)
+	return true
+}
+
+// parseRoutineHeading parses 'procedure' name [params] or 'function' name
+// [params] ':' type. The parameters and result type may be left out when
+// repeating the heading of a routine in forwards.
+func (p *Parser) parseRoutineHeading(forwards map[string]*RoutineDecl) *RoutineDecl {
+	start := p.curToken.Pos
+	decl := &RoutineDecl{IsFunction: p.curTokenIs(token.FUNCTION)}
+
+	// Advance to the next token after 'procedure' or 'function'
+	p.nextToken()
+
+	if !p.curTokenIs(token.IDENT) {
+		p.addError(&ParserError{
+			Msg:    fmt.Sprintf("Expected %s name", decl.Kind()),
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   fmt.Sprintf("The '%s' keyword must be followed by an identifier.", decl.Kind()),
+		})
+		return nil
+	}
+	decl.Name = p.curToken.Literal
+	decl.NameSpan = Span{p.curToken.Pos, p.curToken.End}
+	p.nextToken()
+
+	fwd := forwards[decl.Name]
+
+	if p.curTokenIs(token.LPAREN) {
+		params, ok := p.parseParams()
+		if !ok {
+			return nil
+		}
+		decl.Params = params
+	} else if fwd != nil {
+		decl.Params = fwd.Params
+	}
+
+	if decl.IsFunction {
+		if p.curTokenIs(token.COLON) {
+			// Advance to the next token after ':'
+			p.nextToken()
+
+			typeStart := p.curToken
+			resultType, ok := p.parseTypeName()
+			if !ok {
+				return nil
+			}
+			if _, ok := resultType.(*ArrayType); ok {
+				p.addError(&ParserError{
+					Msg:    fmt.Sprintf("Function '%s' cannot return an array", decl.Name),
+					Detail: fmt.Sprintf("The result type %s is an array type.", resultType),
+					Hint:   "Return the array through a var parameter instead.",
+					Pos:    typeStart.Pos,
+					End:    typeStart.End,
+				})
+				return nil
+			}
+			if IsFile(resultType) {
+				p.addError(&ParserError{
+					Msg:    fmt.Sprintf("Function '%s' cannot return a file", decl.Name),
+					Detail: fmt.Sprintf("The result type %s is or contains a file type.", resultType),
+					Hint:   "Pass the file as a var parameter instead.",
+					Pos:    typeStart.Pos,
+					End:    typeStart.End,
+				})
+				return nil
+			}
+			decl.ResultType = resultType
+		} else if fwd != nil {
+			decl.ResultType = fwd.ResultType
+		} else {
+			p.addError(&ParserError{
+				Msg:    fmt.Sprintf("Expected result type for function '%s'", decl.Name),
+				Detail: fmt.Sprintf("Got %q (%s) instead of ':'.", p.curToken.Literal, p.curToken.Type),
+				Hint:   "A function declares its result type after the parameters, e.g. 'function f(x: integer): integer;'.",
+			})
+			return nil
+		}
+	}
+
+	decl.Span = p.spanFrom(start)
+	return decl
+}
+
+// parseParams parses a formal parameter list: '(' group {';' group} ')',
+// where each group is ['var'] name {, name} ':' type, or the heading of a
+// procedural or functional parameter.
+func (p *Parser) parseParams() ([]*Param, bool) {
+	// Advance to the next token after '('
+	p.nextToken()
+
+	var params []*Param
+	for {
+		start := p.curToken.Pos
+
+		if p.curTokenIs(token.PROCEDURE) || p.curTokenIs(token.FUNCTION) {
+			heading := p.parseRoutineHeading(nil)
+			if heading == nil {
+				return nil, false
+			}
+			params = append(params, &Param{Span: heading.Span, Name: heading.Name, Routine: heading})
+		} else {
+			group, ok := p.parseParamGroup(start)
+			if !ok {
+				return nil, false
+			}
+			params = append(params, group...)
+		}
+
+		if !p.curTokenIs(token.SEMICOLON) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.curTokenIs(token.RPAREN) {
+		p.addError(&ParserError{
+			Msg:    "Expected ')' after parameters",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "Parameter groups are separated by semicolons and the list is closed with ')'.",
+		})
+		return nil, false
+	}
+	p.nextToken()
+
+	return params, true
+}
+
+// parseParamGroup parses ['var'] name {, name} ':' type.
+func (p *Parser) parseParamGroup(start token.Position) ([]*Param, bool) {
+	isVar := p.curTokenIs(token.VAR)
+	if isVar {
+		p.nextToken()
+	}
+
+	names := p.parseIdentList()
+
+	if !p.curTokenIs(token.COLON) {
+		p.addError(&ParserError{
+			Msg:    "Expected ':' after parameter name",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "Parameters are declared like variables, e.g. '(a, b: integer; var c: boolean)'.",
+		})
+		return nil, false
+	}
+	p.nextToken()
+
+	typeStart := p.curToken
+	typ, ok := p.parseTypeName()
+	if !ok {
+		return nil, false
+	}
+	if !isVar && IsFile(typ) {
+		p.addError(&ParserError{
+			Msg:    "File parameters must be var parameters",
+			Detail: fmt.Sprintf("A value parameter would copy its argument, but %s values cannot be copied.", typ),
+			Hint:   "Declare the parameter with 'var', e.g. '(var f: text)'.",
+			Pos:    typeStart.Pos,
+			End:    typeStart.End,
+		})
+	}
+
+	var params []*Param
+	for _, name := range names {
+		params = append(params, &Param{Span: Span{start, p.lastEnd}, Name: name.Value, Type: typ, Var: isVar})
+	}
+	return params, true
+}
+
+// RequiredRoutine is a required procedure or function, such as ord, which
+// every program can call as though it were declared in a block enclosing
+// it; the interpreter supplies its implementation. write, writeln, read
+// and readln are not among them, as they have statements of their own.
+type RequiredRoutine struct {
+	Span
+	Name       string
+	IsFunction bool
+	Params     int  // number of arguments taken
+	ToInput    bool // the file argument may be left out to use the standard input
+	StringType bool // only available in dialects with the string type
+	Statement  bool // called by a statement of its own, which takes any number of arguments
+}
+
+// Kind returns "procedure" or "function", for messages.
+func (r *RequiredRoutine) Kind() string {
+	if r.IsFunction {
+		return "function"
+	}
+	return "procedure"
+}
+
+// RequiredRoutines holds the required procedures and functions.
+var RequiredRoutines = []*RequiredRoutine{
+	{Name: "ord", IsFunction: true, Params: 1},
+	{Name: "succ", IsFunction: true, Params: 1},
+	{Name: "pred", IsFunction: true, Params: 1},
+	{Name: "chr", IsFunction: true, Params: 1},
+	{Name: "length", IsFunction: true, Params: 1, StringType: true},
+	{Name: "trunc", IsFunction: true, Params: 1},
+	{Name: "round", IsFunction: true, Params: 1},
+	{Name: "new", Params: 1},
+	{Name: "dispose", Params: 1},
+	{Name: "assign", Params: 2},
+	{Name: "reset", Params: 1},
+	{Name: "rewrite", Params: 1},
+	{Name: "close", Params: 1},
+	{Name: "eof", IsFunction: true, Params: 1, ToInput: true},
+	{Name: "eoln", IsFunction: true, Params: 1, ToInput: true},
+	{Name: "read", Statement: true},
+	{Name: "readln", Statement: true},
+	{Name: "write", Statement: true},
+}
+
+// checkCall reports a call of name with nargs arguments that cannot
+// succeed: a call of a name that is not declared, or of a routine with a
+// different number of parameters. Calls of names that are declared as
+// something else are left to the interpreter.
+func (p *Parser) checkCall(name string, nargs int, call Node) {
+	var kind string
+	var params int
+	var labels []diagnostics.Label
+	hint := "Pass exactly one argument for each parameter in the declaration."
+
+	switch decl := p.lookup(name).(type) {
+	case nil:
+		p.addError(&ParserError{
+			Msg:    fmt.Sprintf("Undeclared procedure or function '%s'", name),
+			Detail: "This name is called but no procedure or function with that name was declared.",
+			Hint:   "Check the spelling, or declare it before the routine or statement that calls it.",
+			Pos:    call.Pos(),
+			End:    call.End(),
+		})
+		return
+	case *RoutineDecl:
+		kind, params = decl.Kind(), len(decl.Params)
+		labels = []diagnostics.Label{{Pos: decl.NameSpan.Pos(), End: decl.NameSpan.End(), Msg: fmt.Sprintf("'%s' declared here", name)}}
+	case *Param:
+		if decl.Routine == nil {
+			return
+		}
+		kind, params = decl.Kind(), len(decl.Routine.Params)
+		labels = []diagnostics.Label{{Pos: decl.Pos(), End: decl.End(), Msg: "parameter declared here"}}
+	case *RequiredRoutine:
+		if decl.Statement || decl.ToInput && nargs == 0 {
+			return
+		}
+		kind, params = decl.Kind(), decl.Params
+		hint = "Pass exactly one argument for each parameter of the required " + decl.Kind() + "."
+	default:
+		return
+	}
+
+	if nargs != params {
+		p.addError(&ParserError{
+			Msg:    fmt.Sprintf("Wrong number of arguments in call to %s '%s'", kind, name),
+			Detail: fmt.Sprintf("'%s' takes %s, but the call passes %d.", name, plural(params, "argument"), nargs),
+			Hint:   hint,
+			Pos:    call.Pos(),
+			End:    call.End(),
+			Labels: labels,
+		})
+	}
+}
+
+// plural returns "1 argument", "2 arguments" and so on.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
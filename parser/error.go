@@ -1,22 +1,31 @@
 package parser
 
-import "fmt"
+import (
+	"pastel/diagnostics"
+	"pastel/token"
+)
 
 type ParserError struct {
 	Msg    string
 	Detail string
 	Hint   string
-	Line   int // optional for now
-	Column int // optional for now
+	Pos    token.Position
+	End    token.Position
+	Labels []diagnostics.Label // related locations, e.g. an unmatched '('
 }
 
-func (e *ParserError) Error() string {
-	msg := fmt.Sprintf("\n[Parser Error] %s", e.Msg)
-	if e.Detail != "" {
-		msg += fmt.Sprintf("\n  → %s", e.Detail)
-	}
-	if e.Hint != "" {
-		msg += fmt.Sprintf("\n  💡 Hint: %s", e.Hint)
+// Diagnostic describes the error for the diagnostics renderer.
+func (e *ParserError) Diagnostic() *diagnostics.Diagnostic {
+	return &diagnostics.Diagnostic{
+		Kind:      "Parser Error",
+		Msg:       e.Msg,
+		Detail:    e.Detail,
+		Hint:      e.Hint,
+		Primary:   diagnostics.Label{Pos: e.Pos, End: e.End},
+		Secondary: e.Labels,
 	}
-	return msg
+}
+
+func (e *ParserError) Error() string {
+	return "\n" + diagnostics.Format(e.Diagnostic())
 }
@@ -2,18 +2,56 @@ package parser
 
 import (
 	"fmt"
+	"math"
+	"pastel/diagnostics"
 	"pastel/lexer"
 	"pastel/token"
+	"slices"
 	"strconv"
 )
 
-type Expr any
+type Expr interface {
+	Node
+}
 
 type IntegerLiteral struct {
+	Span
 	Value int
 }
 
+// RealLiteral is an unsigned real number, e.g. 3.14 or 1e-5.
+type RealLiteral struct {
+	Span
+	Value float64
+}
+
+type BooleanLiteral struct {
+	Span
+	Value bool
+}
+
+// StringLiteral is a quoted string, e.g. 'data.txt' or 'line'#10. A literal
+// of one character, such as 'a' or #9, is a char.
+type StringLiteral struct {
+	Span
+	Value string
+}
+
+// IsChar reports whether the literal is of type char.
+func (s *StringLiteral) IsChar() bool {
+	return len(s.Value) == 1
+}
+
+// UnaryExpr is a prefix operator applied to a single operand, e.g. 'not done'
+// or '-x'.
+type UnaryExpr struct {
+	Span
+	Operator token.Token
+	Operand  Expr
+}
+
 type BinaryExpr struct {
+	Span
 	Left     Expr
 	Operator token.Token
 	Right    Expr
@@ -23,16 +61,102 @@ type Parser struct {
 	l         *lexer.Lexer
 	curToken  token.Token
 	peekToken token.Token
+	lastEnd   token.Position // end of the most recently consumed token
 	errors    []*ParserError
+	lexErrors int // number of lexer errors already copied into errors
+
+	// forVars holds the control variables of the for statements being
+	// parsed, innermost last, so their bodies cannot assign to them.
+	forVars []*Identifier
+
+	// scopes holds the names declared by the blocks being parsed, innermost
+	// last, so that constants can be resolved while parsing.
+	scopes []scope
+
+	// enumConsts holds the constants of enumerated types parsed in the
+	// current section, until they are added to its declarations.
+	enumConsts []Stmt
+
+	// pointers holds the pointer types parsed in the current section, whose
+	// domain type may be declared later in the section.
+	pointers []*pointerDomain
 }
 
 type Identifier struct {
+	Span
 	Value string
 }
 
+// IndexExpr selects the component of the array Array at Index. An access
+// with several indices, 'a[i, j]', is parsed as 'a[i][j]'.
+type IndexExpr struct {
+	Span
+	Array Expr
+	Index Expr
+}
+
+// FieldExpr selects the field Field of the record Record, e.g. 'p.x'.
+type FieldExpr struct {
+	Span
+	Record    Expr
+	Field     string
+	FieldSpan Span
+}
+
+// DerefExpr is 'Pointer^', the variable the pointer Pointer points to.
+type DerefExpr struct {
+	Span
+	Pointer Expr
+}
+
+// NilLiteral is 'nil', the pointer value that points to no variable.
+type NilLiteral struct {
+	Span
+}
+
+// SetConstructor is a set value built from its members, e.g. '[1, 3..5]'.
+type SetConstructor struct {
+	Span
+	Members []*SetMember
+}
+
+// SetMember is a member 'Low' or a range 'Low..High' of a set constructor.
+// High is nil for a single member.
+type SetMember struct {
+	Span
+	Low, High Expr
+}
+
+// CallExpr is a function call with arguments, e.g. 'max(a, b)'. A call
+// without arguments is parsed as an Identifier.
+type CallExpr struct {
+	Span
+	Name string
+	Args []Expr
+}
+
 // New creates a new Parser instance with the given lexer.
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{l: l}
+	p.pushScope()
+	for _, decl := range Universe {
+		p.declare(decl.Name, decl)
+	}
+	for _, decl := range UniverseTypes {
+		if decl.Type == String && !l.Dialect().StringType {
+			continue
+		}
+		p.declare(decl.Name, decl)
+	}
+	for _, decl := range StandardFiles {
+		p.declare(decl.Name, decl)
+	}
+	for _, decl := range RequiredRoutines {
+		if decl.StringType && !l.Dialect().StringType {
+			continue
+		}
+		p.declare(decl.Name, decl)
+	}
 	p.nextToken()
 	p.nextToken()
 	return p
@@ -47,21 +171,24 @@ func (p *Parser) Errors() []*ParserError {
 }
 
 // ParseExpression parses an expression in Pascal.
-// Expressions include arithmetic operations like addition, subtraction, multiplication, and division.
+// Expressions combine arithmetic, relational and logical operators with the
+// ISO precedence levels: relational operators and 'in' bind loosest, then the adding
+// operators (+ - or), then the multiplying operators (* / and), then 'not'.
 func (p *Parser) ParseExpression() Expr {
-	return p.parseAddition()
+	return p.parseRelational()
 }
 
 // ParseProgram parses a complete Pascal program.
 // A Pascal program starts with the 'program' keyword, followed by declarations and a main compound statement.
 func (p *Parser) ParseProgram() *Program {
 	prog := &Program{}
+	prog.StartPos = p.curToken.Pos
 
 	if p.curToken.Type == token.PROGRAM {
 		// Advance to the next token after 'program' keyword
 		p.nextToken()
 	} else {
-		p.errors = append(p.errors, &ParserError{
+		p.addError(&ParserError{
 			Msg:    "Expected 'program' keyword",
 			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
 			Hint:   "A Pascal program must start with the 'program' keyword.",
@@ -70,7 +197,7 @@ func (p *Parser) ParseProgram() *Program {
 	}
 
 	if p.curToken.Type != token.IDENT {
-		p.errors = append(p.errors, &ParserError{
+		p.addError(&ParserError{
 			Msg:    "Expected program name",
 			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
 			Hint:   "The 'program' keyword must be followed by an identifier.",
@@ -82,8 +209,26 @@ func (p *Parser) ParseProgram() *Program {
 	prog.Name = p.curToken.Literal
 	p.nextToken()
 
+	// The program parameters, e.g. '(input, output)', name the files the
+	// program uses; input and output are always available.
+	if p.curTokenIs(token.LPAREN) {
+		lparen := p.curToken
+		p.nextToken()
+		prog.Params = p.parseIdentList()
+		if !p.curTokenIs(token.RPAREN) {
+			p.addError(&ParserError{
+				Msg:    "Expected ')' after program parameters",
+				Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+				Hint:   "Program parameters are names separated by commas, e.g. 'program copy(input, output);'.",
+				Labels: []diagnostics.Label{{Pos: lparen.Pos, End: lparen.End, Msg: "unclosed '(' opened here"}},
+			})
+			return nil
+		}
+		p.nextToken()
+	}
+
 	if p.curToken.Type != token.SEMICOLON {
-		p.errors = append(p.errors, &ParserError{
+		p.addError(&ParserError{
 			Msg:    "Expected semicolon",
 			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
 			Hint:   "Statements must end with a semicolon.",
@@ -94,17 +239,13 @@ func (p *Parser) ParseProgram() *Program {
 	// Advance to the next token after the semicolon
 	p.nextToken()
 
-	var decls []Stmt
-	for p.curToken.Type == token.VAR {
-		decl := p.parseVarDecl()
-		if decl != nil {
-			decls = append(decls, decl)
-		}
-	}
-	prog.Declarations = decls
+	p.pushScope()
+	defer p.popScope()
+
+	prog.Declarations = p.parseDeclarations()
 
 	if p.curToken.Type != token.BEGIN {
-		p.errors = append(p.errors, &ParserError{
+		p.addError(&ParserError{
 			Msg:    "Expected 'begin' block",
 			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
 			Hint:   "A Pascal program must have a 'begin' block to define its main body.",
@@ -116,7 +257,7 @@ func (p *Parser) ParseProgram() *Program {
 	stmt := p.parseCompound()
 	compound, ok := stmt.(*CompoundStmt)
 	if !ok {
-		p.errors = append(p.errors, &ParserError{
+		p.addError(&ParserError{
 			Msg:    "Expected compound statement",
 			Detail: "The main body of the program must be a compound statement.",
 			Hint:   "Ensure the program's main body starts with 'begin' and ends with 'end'.",
@@ -126,8 +267,14 @@ func (p *Parser) ParseProgram() *Program {
 
 	prog.Main = compound
 
+	// Errors inside the main block leave the parser out of step with the
+	// source, so don't pile a misleading '.' error on top of them.
+	if p.HasErrors() {
+		return prog
+	}
+
 	if p.curToken.Type != token.DOT {
-		p.errors = append(p.errors, &ParserError{
+		p.addError(&ParserError{
 			Msg:    "Expected '.' at the end of the program",
 			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
 			Hint:   "A Pascal program must end with a period ('.').",
@@ -135,25 +282,34 @@ func (p *Parser) ParseProgram() *Program {
 		return nil
 	}
 
+	prog.EndPos = p.curToken.End
 	return prog
 }
 
 // ParseStatement parses a single Pascal statement.
-// Statements include assignments, compound statements, and print statements.
+// Statements include assignments, procedure calls, compound statements, if and case statements,
+// loops and print statements.
+// On return the current token is the first token after the statement;
+// separating semicolons are left for parseCompound.
 func (p *Parser) parseStatement() Stmt {
 	switch p.curToken.Type {
 	case token.IDENT:
-		// Look ahead to see if this is an assignment (IDENT := ...)
-		if p.peekToken.Type == token.ASSIGN {
+		// Look ahead to see if this is an assignment (IDENT := ...), or
+		// an assignment to a component (IDENT[...] := ..., IDENT.f := ...,
+		// IDENT^ := ...)
+		switch p.peekToken.Type {
+		case token.ASSIGN, token.LBRACKET, token.DOT, token.CARET:
 			return p.parseAssignment()
 		}
-		p.errors = append(p.errors, &ParserError{
-			Msg:    fmt.Sprintf("Unexpected identifier '%s'", p.curToken.Literal),
-			Detail: "This identifier is not part of an assignment or recognized statement.",
-			Hint:   "Make sure you're using ':=' for assignments or a known keyword like 'writeln'.",
-		})
-		p.nextToken()
-		return nil
+		// write, read and readln have statements of their own, unless the
+		// program declares the name for something else.
+		if r, ok := p.lookup(p.curToken.Literal).(*RequiredRoutine); ok && r.Statement {
+			if r.Name == "write" {
+				return p.parsePrint()
+			}
+			return p.parseRead()
+		}
+		return p.parseCallStmt()
 
 	case token.WRITELN:
 		return p.parsePrint()
@@ -161,7 +317,26 @@ func (p *Parser) parseStatement() Stmt {
 	case token.BEGIN:
 		return p.parseCompound()
 
+	case token.IF:
+		return p.parseIf()
+
+	case token.WHILE:
+		return p.parseWhile()
+
+	case token.REPEAT:
+		return p.parseRepeat()
+
+	case token.FOR:
+		return p.parseFor()
+
+	case token.CASE:
+		return p.parseCase()
+
+	case token.WITH:
+		return p.parseWith()
+
 	default:
+		// The empty statement
 		return nil
 	}
 }
@@ -169,12 +344,24 @@ func (p *Parser) parseStatement() Stmt {
 // ParseAssignment parses an assignment statement in Pascal.
 // Assignment statements use the ':=' operator to assign values to variables.
 func (p *Parser) parseAssignment() Stmt {
+	start := p.curToken.Pos
 	name := p.curToken.Literal // We are on IDENT
 
-	if !p.expectPeek(token.ASSIGN) {
-		p.errors = append(p.errors, &ParserError{
-			Msg:    "Expected ':=' after identifier",
-			Detail: fmt.Sprintf("Got %q (%s) instead.", p.peekToken.Literal, p.peekToken.Type),
+	p.checkNotConstant(name)
+	p.checkNotControlVariable(name)
+
+	ident := &Identifier{Span: Span{p.curToken.Pos, p.curToken.End}, Value: name}
+	p.nextToken()
+
+	target := p.parseSelectors(ident)
+	if target == nil {
+		return nil
+	}
+
+	if !p.curTokenIs(token.ASSIGN) {
+		p.addError(&ParserError{
+			Msg:    "Expected ':=' after variable",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
 			Hint:   "Assignments must use the ':=' operator.",
 		})
 		return nil
@@ -183,97 +370,776 @@ func (p *Parser) parseAssignment() Stmt {
 	p.nextToken()
 	value := p.ParseExpression()
 
-	if !p.curTokenIs(token.SEMICOLON) {
-		p.errors = append(p.errors, &ParserError{
-			Msg:    "Expected semicolon at the end of assignment",
+	return &AssignStmt{Span: p.spanFrom(start), Target: target, Value: value}
+}
+
+// ParseCallStmt parses a procedure call statement: name ['(' arguments ')'].
+func (p *Parser) parseCallStmt() Stmt {
+	start := p.curToken.Pos
+	name := p.curToken.Literal
+
+	// Advance to the next token after the procedure name
+	p.nextToken()
+
+	var args []Expr
+	if p.curTokenIs(token.LPAREN) {
+		var ok bool
+		if args, ok = p.parseArgs(); !ok {
+			return nil
+		}
+	} else if !p.atStatementEnd() {
+		p.addError(&ParserError{
+			Msg:    fmt.Sprintf("Unexpected %q after '%s'", p.curToken.Literal, name),
+			Detail: "An identifier on its own is a procedure call, which may only be followed by its arguments in parentheses.",
+			Hint:   "Make sure you're using ':=' for assignments or '(...)' to pass arguments.",
+		})
+		return nil
+	}
+
+	stmt := &CallStmt{Span: p.spanFrom(start), Name: name, Args: args}
+	p.checkCall(name, len(args), stmt)
+	return stmt
+}
+
+// parseArgs parses an argument list: '(' expression {',' expression} ')'.
+func (p *Parser) parseArgs() ([]Expr, bool) {
+	lparen := p.curToken
+
+	// Advance to the next token after '('
+	p.nextToken()
+
+	var args []Expr
+	for {
+		args = append(args, p.ParseExpression())
+		if !p.curTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.curTokenIs(token.RPAREN) {
+		p.addError(&ParserError{
+			Msg:    "Expected ')' after arguments",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "Arguments are separated by commas and the list is closed with ')'.",
+			Labels: []diagnostics.Label{{Pos: lparen.Pos, End: lparen.End, Msg: "unclosed '(' opened here"}},
+		})
+		return nil, false
+	}
+
+	// Consume ')'
+	p.nextToken()
+
+	return args, true
+}
+
+// parseSetConstructor parses '[' [member {',' member}] ']', where each
+// member is an expression or a range 'expression .. expression'.
+func (p *Parser) parseSetConstructor() Expr {
+	start := p.curToken.Pos
+	lbracket := p.curToken
+
+	// Advance to the next token after '['
+	p.nextToken()
+
+	set := &SetConstructor{}
+	for !p.curTokenIs(token.RBRACKET) {
+		memberStart := p.curToken.Pos
+		member := &SetMember{Low: p.ParseExpression()}
+		if p.curTokenIs(token.DOTDOT) {
+			p.nextToken()
+			member.High = p.ParseExpression()
+		}
+		member.Span = p.spanFrom(memberStart)
+		set.Members = append(set.Members, member)
+
+		if !p.curTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.curTokenIs(token.RBRACKET) {
+		p.addError(&ParserError{
+			Msg:    "Expected ']' after set members",
 			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
-			Hint:   "Assignments must end with a semicolon.",
+			Hint:   "Set members are separated by commas and the set is closed with ']', e.g. '[1, 3..5]'.",
+			Labels: []diagnostics.Label{{Pos: lbracket.Pos, End: lbracket.End, Msg: "unclosed '[' opened here"}},
 		})
 		return nil
 	}
 
-	return &AssignStmt{Name: name, Value: value}
+	// Consume ']'
+	p.nextToken()
+
+	set.Span = p.spanFrom(start)
+	return set
+}
+
+// parseSelectors parses the selectors that follow the variable base, such
+// as '[i, j]', '.field' or '^', and returns the variable they select.
+func (p *Parser) parseSelectors(base Expr) Expr {
+	for p.curTokenIs(token.LBRACKET) || p.curTokenIs(token.DOT) || p.curTokenIs(token.CARET) {
+		if p.curTokenIs(token.CARET) {
+			base = &DerefExpr{Span: Span{base.Pos(), p.curToken.End}, Pointer: base}
+			p.nextToken()
+			continue
+		}
+
+		if p.curTokenIs(token.DOT) {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			base = &FieldExpr{
+				Span:      Span{base.Pos(), p.curToken.End},
+				Record:    base,
+				Field:     p.curToken.Literal,
+				FieldSpan: Span{p.curToken.Pos, p.curToken.End},
+			}
+			p.nextToken()
+			continue
+		}
+
+		lbracket := p.curToken
+
+		// Advance to the next token after '['
+		p.nextToken()
+
+		for {
+			index := p.ParseExpression()
+			base = &IndexExpr{Span: p.spanFrom(base.Pos()), Array: base, Index: index}
+			if !p.curTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+
+		if !p.curTokenIs(token.RBRACKET) {
+			p.addError(&ParserError{
+				Msg:    "Expected ']' after index",
+				Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+				Hint:   "Indices are separated by commas and the list is closed with ']'.",
+				Labels: []diagnostics.Label{{Pos: lbracket.Pos, End: lbracket.End, Msg: "unclosed '[' opened here"}},
+			})
+			return nil
+		}
+
+		// Consume ']'
+		p.nextToken()
+		base.(*IndexExpr).Span = p.spanFrom(base.Pos())
+	}
+	return base
+}
+
+// atStatementEnd reports whether the current token can follow a statement.
+func (p *Parser) atStatementEnd() bool {
+	switch p.curToken.Type {
+	case token.SEMICOLON, token.END, token.ELSE, token.UNTIL, token.OTHERWISE, token.EOF:
+		return true
+	}
+	return false
 }
 
 // ParseCompound parses a compound statement in Pascal.
-// Compound statements start with 'begin', contain multiple statements, and end with 'end'.
+// Compound statements start with 'begin', contain statements separated by semicolons, and end with 'end'.
 func (p *Parser) parseCompound() Stmt {
-	stmts := []Stmt{}
+	begin := p.curToken
+	start := begin.Pos
 
+	// Advance to the next token after 'begin'
 	p.nextToken()
 
-	for p.curToken.Type != token.END && p.curToken.Type != token.EOF && p.curToken.Type != token.DOT {
+	stmts := p.parseStatementSequence()
+
+	if !p.curTokenIs(token.END) {
+		p.addError(&ParserError{
+			Msg:    "Expected ';' or 'end'",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "Statements inside a block must be separated by semicolons, and the block must end with 'end'.",
+			Labels: []diagnostics.Label{{Pos: begin.Pos, End: begin.End, Msg: "block opened here"}},
+		})
+		return &CompoundStmt{Span: p.spanFrom(start), Statements: stmts}
+	}
+
+	// Consume 'end'
+	p.nextToken()
+
+	return &CompoundStmt{Span: p.spanFrom(start), Statements: stmts}
+}
+
+// parseStatementSequence parses statements separated by semicolons, up to
+// the token that ends the sequence ('end' or 'until'), which it leaves for
+// the caller.
+func (p *Parser) parseStatementSequence() []Stmt {
+	stmts := []Stmt{}
+
+	for {
 		stmt := p.parseStatement()
 		if stmt != nil {
 			stmts = append(stmts, stmt)
 		}
 
+		if !p.curTokenIs(token.SEMICOLON) {
+			break
+		}
 		p.nextToken()
 	}
 
-	return &CompoundStmt{Statements: stmts}
+	return stmts
 }
 
-// ParsePrint parses a print statement in Pascal.
-// Print statements use the 'writeln' keyword to output values.
-func (p *Parser) parsePrint() Stmt {
-	if !p.curTokenIs(token.WRITELN) {
-		p.errors = append(p.errors, &ParserError{
-			Msg:    "Expected 'writeln' keyword",
+// ParseIf parses an if statement: 'if' condition 'then' statement ['else' statement].
+// An 'else' always belongs to the nearest 'if' that has none yet, which is
+// what parsing the 'then' branch first gives us.
+func (p *Parser) parseIf() Stmt {
+	start := p.curToken.Pos
+
+	// Advance to the next token after 'if'
+	p.nextToken()
+
+	cond := p.ParseExpression()
+
+	if !p.curTokenIs(token.THEN) {
+		p.addError(&ParserError{
+			Msg:    "Expected 'then' after if condition",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "An if statement is written 'if condition then statement'.",
+		})
+		return nil
+	}
+
+	// Advance to the next token after 'then'
+	p.nextToken()
+
+	stmt := &IfStmt{Condition: cond}
+	stmt.Then = p.parseStatement()
+
+	if p.curTokenIs(token.SEMICOLON) && p.peekToken.Type == token.ELSE {
+		p.addError(&ParserError{
+			Msg:    "Unexpected ';' before 'else'",
+			Detail: "The semicolon ends the if statement, so the 'else' has no 'if' to belong to.",
+			Hint:   "Remove the ';' in front of 'else'.",
+		})
+		p.nextToken()
+	}
+
+	if p.curTokenIs(token.ELSE) {
+		// Advance to the next token after 'else'
+		p.nextToken()
+		stmt.Else = p.parseStatement()
+	}
+
+	stmt.Span = p.spanFrom(start)
+	return stmt
+}
+
+// ParseWhile parses a while statement: 'while' condition 'do' statement.
+func (p *Parser) parseWhile() Stmt {
+	start := p.curToken.Pos
+
+	// Advance to the next token after 'while'
+	p.nextToken()
+
+	cond := p.ParseExpression()
+
+	if !p.curTokenIs(token.DO) {
+		p.addError(&ParserError{
+			Msg:    "Expected 'do' after while condition",
 			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
-			Hint:   "Use 'writeln' to print values.",
+			Hint:   "A while loop is written 'while condition do statement'.",
 		})
 		return nil
 	}
 
-	// Advance to the next token after 'writeln'
+	// Advance to the next token after 'do'
+	p.nextToken()
+
+	body := p.parseStatement()
+
+	return &WhileStmt{Span: p.spanFrom(start), Condition: cond, Body: body}
+}
+
+// ParseRepeat parses a repeat statement: 'repeat' statements 'until' condition.
+func (p *Parser) parseRepeat() Stmt {
+	repeat := p.curToken
+
+	// Advance to the next token after 'repeat'
 	p.nextToken()
 
-	if !p.curTokenIs(token.LPAREN) {
-		p.errors = append(p.errors, &ParserError{
-			Msg:    "Expected '(' after 'writeln'",
+	body := p.parseStatementSequence()
+
+	if !p.curTokenIs(token.UNTIL) {
+		p.addError(&ParserError{
+			Msg:    "Expected ';' or 'until'",
 			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
-			Hint:   "The 'writeln' keyword must be followed by parentheses containing the argument.",
+			Hint:   "A repeat loop is written 'repeat statements until condition'.",
+			Labels: []diagnostics.Label{{Pos: repeat.Pos, End: repeat.End, Msg: "loop starts here"}},
 		})
 		return nil
 	}
 
-	// Advance to the next token after '('
+	// Advance to the next token after 'until'
 	p.nextToken()
 
-	arg := p.ParseExpression()
+	cond := p.ParseExpression()
 
-	if !p.curTokenIs(token.RPAREN) {
-		p.errors = append(p.errors, &ParserError{
-			Msg:    "Expected ')' after writeln argument",
+	return &RepeatStmt{Span: p.spanFrom(repeat.Pos), Body: body, Condition: cond}
+}
+
+// ParseFor parses a for statement:
+// 'for' variable ':=' initial ('to' | 'downto') final 'do' statement.
+func (p *Parser) parseFor() Stmt {
+	start := p.curToken.Pos
+
+	// Advance to the next token after 'for'
+	p.nextToken()
+
+	if !p.curTokenIs(token.IDENT) {
+		p.addError(&ParserError{
+			Msg:    "Expected control variable after 'for'",
 			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
-			Hint:   "Ensure the argument to 'writeln' is enclosed in parentheses.",
+			Hint:   "A for loop is written 'for i := first to last do statement'.",
 		})
 		return nil
 	}
 
-	// Advance to the next token after ')'
+	p.checkNotConstant(p.curToken.Literal)
+	p.checkNotControlVariable(p.curToken.Literal)
+	variable := &Identifier{Span: Span{p.curToken.Pos, p.curToken.End}, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+
+	// Advance to the next token after ':='
 	p.nextToken()
 
-	if !p.curTokenIs(token.SEMICOLON) {
-		p.errors = append(p.errors, &ParserError{
-			Msg:    "Expected ';' after writeln",
+	initial := p.ParseExpression()
+
+	if !p.curTokenIs(token.TO) && !p.curTokenIs(token.DOWNTO) {
+		p.addError(&ParserError{
+			Msg:    "Expected 'to' or 'downto' in for loop",
 			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
-			Hint:   "Statements must end with a semicolon.",
+			Hint:   "Use 'to' to count up or 'downto' to count down.",
 		})
 		return nil
 	}
+	downto := p.curTokenIs(token.DOWNTO)
 
-	// Advance to the next token after the semicolon
+	// Advance to the next token after 'to' or 'downto'
+	p.nextToken()
+
+	final := p.ParseExpression()
+
+	if !p.curTokenIs(token.DO) {
+		p.addError(&ParserError{
+			Msg:    "Expected 'do' in for loop",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "A for loop is written 'for i := first to last do statement'.",
+		})
+		return nil
+	}
+
+	// Advance to the next token after 'do'
+	p.nextToken()
+
+	p.forVars = append(p.forVars, variable)
+	body := p.parseStatement()
+	p.forVars = p.forVars[:len(p.forVars)-1]
+
+	return &ForStmt{
+		Span:     p.spanFrom(start),
+		Variable: variable,
+		Initial:  initial,
+		Final:    final,
+		Downto:   downto,
+		Body:     body,
+	}
+}
+
+// ParseCase parses a case statement:
+// 'case' selector 'of' arm {';' arm} [';'] ['else' | 'otherwise' statements] 'end',
+// where each arm is 'label {',' label} ':' statement' and a label is a
+// constant or a constant range 'lo..hi'. Labels that repeat or overlap
+// are rejected here, before the program runs.
+func (p *Parser) parseCase() Stmt {
+	caseTok := p.curToken
+
+	// Advance to the next token after 'case'
+	p.nextToken()
+
+	selector := p.ParseExpression()
+
+	if !p.curTokenIs(token.OF) {
+		p.addError(&ParserError{
+			Msg:    "Expected 'of' after case selector",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "A case statement is written 'case expression of ... end'.",
+		})
+		return nil
+	}
+
+	// Advance to the next token after 'of'
+	p.nextToken()
+
+	stmt := &CaseStmt{Selector: selector}
+	var seen []caseRange
+
+	for !p.curTokenIs(token.END) && !p.curTokenIs(token.ELSE) && !p.curTokenIs(token.OTHERWISE) && !p.curTokenIs(token.EOF) {
+		arm := p.parseCaseArm(&seen)
+		if arm == nil {
+			return nil
+		}
+		stmt.Arms = append(stmt.Arms, arm)
+
+		if !p.curTokenIs(token.SEMICOLON) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if p.curTokenIs(token.ELSE) || p.curTokenIs(token.OTHERWISE) {
+		if !p.l.Dialect().CaseElse {
+			p.addError(&ParserError{
+				Msg:    fmt.Sprintf("'%s' arm in case statement is not allowed in the %s dialect", p.curToken.Literal, p.l.Dialect().Name),
+				Detail: "ISO 7185 Pascal requires one of the case labels to match the selector.",
+				Hint:   "List every possible value in the labels, or choose the pastel, turbo or fpc dialect.",
+			})
+		}
+
+		// Advance to the next token after 'else' or 'otherwise'
+		p.nextToken()
+
+		stmt.HasElse = true
+		stmt.Else = p.parseStatementSequence()
+	}
+
+	if !p.curTokenIs(token.END) {
+		p.addError(&ParserError{
+			Msg:    "Expected 'end' to close case statement",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "Case arms must be separated by semicolons, and the case statement must end with 'end'.",
+			Labels: []diagnostics.Label{{Pos: caseTok.Pos, End: caseTok.End, Msg: "case statement starts here"}},
+		})
+		return nil
+	}
+
+	// Consume 'end'
+	p.nextToken()
+
+	stmt.Span = p.spanFrom(caseTok.Pos)
+	return stmt
+}
+
+// caseRange is the ordinal range covered by a case label that has already
+// been parsed, kept to detect duplicate labels.
+type caseRange struct {
+	low, high int
+	label     *CaseLabel
+}
+
+func (p *Parser) parseCaseArm(seen *[]caseRange) *CaseArm {
+	start := p.curToken.Pos
+	arm := &CaseArm{}
+
+	for {
+		label := p.parseCaseLabel(seen)
+		if label == nil {
+			return nil
+		}
+		arm.Labels = append(arm.Labels, label)
+
+		if !p.curTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.curTokenIs(token.COLON) {
+		p.addError(&ParserError{
+			Msg:    "Expected ':' after case label",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "Each case arm is written 'label: statement', with labels separated by commas.",
+		})
+		return nil
+	}
+
+	// Advance to the next token after ':'
+	p.nextToken()
+
+	arm.Body = p.parseStatement()
+	arm.Span = p.spanFrom(start)
+	return arm
+}
+
+func (p *Parser) parseCaseLabel(seen *[]caseRange) *CaseLabel {
+	start := p.curToken.Pos
+	label := &CaseLabel{Low: p.ParseExpression()}
+
+	if p.curTokenIs(token.DOTDOT) {
+		// Advance to the next token after '..'
+		p.nextToken()
+		label.High = p.ParseExpression()
+	}
+	label.Span = p.spanFrom(start)
+
+	low, ok := p.constOrdinal(label.Low)
+	if !ok {
+		return nil
+	}
+	high := low
+	if label.High != nil {
+		if high, ok = p.constOrdinal(label.High); !ok {
+			return nil
+		}
+		if low > high {
+			p.addError(&ParserError{
+				Msg:    "Empty case label range",
+				Detail: "The lower bound of the range is greater than the upper bound, so no value can match it.",
+				Hint:   "Write the smaller value first, e.g. '1..5'.",
+				Pos:    label.Pos(),
+				End:    label.End(),
+			})
+		}
+	}
+
+	for _, prev := range *seen {
+		if low <= prev.high && prev.low <= high {
+			p.addError(&ParserError{
+				Msg:    "Duplicate case label",
+				Detail: "A value in this label is already covered by an earlier label of the same case statement.",
+				Hint:   "Each value may appear in only one case label.",
+				Pos:    label.Pos(),
+				End:    label.End(),
+				Labels: []diagnostics.Label{{Pos: prev.label.Pos(), End: prev.label.End(), Msg: "first used here"}},
+			})
+			break
+		}
+	}
+	*seen = append(*seen, caseRange{low: low, high: high, label: label})
+
+	return label
+}
+
+// ParseWith parses a with statement:
+// 'with' variable {',' variable} 'do' statement.
+// The fields of each record are in scope in the statement, so that they
+// hide constants of the same name.
+func (p *Parser) parseWith() Stmt {
+	start := p.curToken.Pos
+
+	// Advance to the next token after 'with'
+	p.nextToken()
+
+	var records []Expr
+	for {
+		if !p.curTokenIs(token.IDENT) {
+			p.addError(&ParserError{
+				Msg:    "Expected record variable after 'with'",
+				Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+				Hint:   "A with statement is written 'with r do statement'.",
+			})
+			return nil
+		}
+		ident := &Identifier{Span: Span{p.curToken.Pos, p.curToken.End}, Value: p.curToken.Literal}
+		p.nextToken()
+
+		record := p.parseSelectors(ident)
+		if record == nil {
+			return nil
+		}
+		records = append(records, record)
+
+		// Each record's fields are in scope for the records after it, as
+		// 'with a, b do s' means 'with a do with b do s'.
+		p.pushScope()
+		defer p.popScope()
+		if r, ok := p.staticType(record).(*RecordType); ok {
+			for _, field := range r.Fields {
+				p.declare(field.Name, field)
+			}
+		}
+
+		if !p.curTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.curTokenIs(token.DO) {
+		p.addError(&ParserError{
+			Msg:    "Expected 'do' in with statement",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "A with statement is written 'with r do statement'.",
+		})
+		return nil
+	}
+
+	// Advance to the next token after 'do'
+	p.nextToken()
+
+	body := p.parseStatement()
+
+	return &WithStmt{Span: p.spanFrom(start), Records: records, Body: body}
+}
+
+// checkNotControlVariable reports an error if name, about to be assigned at
+// the current token, is the control variable of an enclosing for loop.
+func (p *Parser) checkNotControlVariable(name string) {
+	for _, v := range slices.Backward(p.forVars) {
+		if v.Value == name {
+			p.addError(&ParserError{
+				Msg:    fmt.Sprintf("Assignment to for-loop control variable '%s'", name),
+				Detail: "The control variable of a for loop may not be changed inside the loop.",
+				Hint:   "Use a while loop if the loop needs to adjust its own counter.",
+				Labels: []diagnostics.Label{{Pos: v.Pos(), End: v.End(), Msg: "controlled by this loop"}},
+			})
+			return
+		}
+	}
+}
+
+// parsePrint parses 'write' or 'writeln' followed by its arguments in
+// parentheses, each optionally with a field width and, for reals, a number
+// of decimal places. A first argument that is a file variable selects the
+// file written to. 'writeln' on its own ends the current line.
+func (p *Parser) parsePrint() Stmt {
+	start := p.curToken.Pos
+	newline := p.curTokenIs(token.WRITELN)
+
+	file, args, ok := p.parseIOArgs(newline, true)
+	if !ok {
+		return nil
+	}
+	return &PrintStmt{Span: p.spanFrom(start), Newline: newline, File: file, Args: args}
+}
+
+// parseRead parses 'read' or 'readln' followed by its arguments in
+// parentheses, like parsePrint. 'readln' on its own skips the rest of the
+// current line of the standard input.
+func (p *Parser) parseRead() Stmt {
+	start := p.curToken.Pos
+	newline := p.curToken.Literal == "readln"
+
+	file, args, ok := p.parseIOArgs(newline, false)
+	if !ok {
+		return nil
+	}
+	stmt := &ReadStmt{Span: p.spanFrom(start), Newline: newline, File: file}
+	for _, arg := range args {
+		stmt.Args = append(stmt.Args, arg.Value)
+	}
+	return stmt
+}
+
+// parseIOArgs parses the arguments of the input/output procedure named by
+// the current token, and separates out a leading file argument. If bare is
+// set, the procedure may be called without arguments; if formatted is set,
+// the arguments may have field widths.
+func (p *Parser) parseIOArgs(bare, formatted bool) (file Expr, args []*WriteArg, ok bool) {
+	name := p.curToken.Literal
+
+	if bare && p.peekToken.Type != token.LPAREN {
+		p.nextToken()
+		return nil, nil, true
+	}
+	if p.peekToken.Type != token.LPAREN {
+		p.addError(&ParserError{
+			Msg:    fmt.Sprintf("Expected '(' after '%s'", name),
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.peekToken.Literal, p.peekToken.Type),
+			Hint:   fmt.Sprintf("The arguments of '%s' are enclosed in parentheses, e.g. '%s(x)'.", name, name),
+			Pos:    p.peekToken.Pos,
+			End:    p.peekToken.End,
+		})
+		return nil, nil, false
+	}
+	p.nextToken()
+	lparen := p.curToken
+
+	// Advance to the next token after '('
+	p.nextToken()
+
+	for {
+		start := p.curToken.Pos
+		arg := &WriteArg{Value: p.ParseExpression()}
+		if formatted && p.curTokenIs(token.COLON) {
+			p.nextToken()
+			arg.Width = p.ParseExpression()
+			if p.curTokenIs(token.COLON) {
+				p.nextToken()
+				arg.Places = p.ParseExpression()
+			}
+		}
+		arg.Span = p.spanFrom(start)
+		args = append(args, arg)
+
+		if !p.curTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.curTokenIs(token.RPAREN) {
+		hint := "Arguments are separated by commas and the list is closed with ')'."
+		if formatted {
+			hint = "Arguments are separated by commas, and each may be followed by a field width, e.g. 'x:8' or 'r:10:3'."
+		}
+		p.addError(&ParserError{
+			Msg:    fmt.Sprintf("Expected ')' after arguments of '%s'", name),
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   hint,
+			Labels: []diagnostics.Label{{Pos: lparen.Pos, End: lparen.End, Msg: "unclosed '(' opened here"}},
+		})
+		return nil, nil, false
+	}
+
+	// Consume ')'
 	p.nextToken()
 
-	return &PrintStmt{Argument: arg}
+	if first := args[0]; first.Width == nil {
+		if _, isFile := p.staticType(first.Value).(*FileType); isFile {
+			return first.Value, args[1:], true
+		}
+	}
+	return nil, args, true
 }
 
 func PrintExpr(expr Expr, indent string) {
 	switch e := expr.(type) {
 	case *IntegerLiteral:
 		fmt.Printf("%sInteger: %d\n", indent, e.Value)
+	case *RealLiteral:
+		fmt.Printf("%sReal: %g\n", indent, e.Value)
+	case *BooleanLiteral:
+		fmt.Printf("%sBoolean: %t\n", indent, e.Value)
+	case *Identifier:
+		fmt.Printf("%sIdentifier: %s\n", indent, e.Value)
+	case *StringLiteral:
+		fmt.Printf("%sString: %q\n", indent, e.Value)
+	case *NilLiteral:
+		fmt.Printf("%sNil\n", indent)
+	case *SetConstructor:
+		fmt.Printf("%sSetConstructor:\n", indent)
+		for _, m := range e.Members {
+			PrintExpr(m.Low, indent+"  ")
+			if m.High != nil {
+				fmt.Printf("%s  ..\n", indent)
+				PrintExpr(m.High, indent+"  ")
+			}
+		}
+	case *DerefExpr:
+		fmt.Printf("%sDerefExpr:\n", indent)
+		PrintExpr(e.Pointer, indent+"  ")
+	case *FieldExpr:
+		fmt.Printf("%sFieldExpr: %s\n", indent, e.Field)
+		PrintExpr(e.Record, indent+"  ")
+	case *IndexExpr:
+		fmt.Printf("%sIndexExpr:\n", indent)
+		PrintExpr(e.Array, indent+"  ")
+		PrintExpr(e.Index, indent+"  ")
+	case *UnaryExpr:
+		fmt.Printf("%sUnaryExpr: %s\n", indent, e.Operator.Literal)
+		PrintExpr(e.Operand, indent+"  ")
 	case *BinaryExpr:
 		fmt.Printf("%sBinaryExpr: %s\n", indent, e.Operator.Literal)
 		PrintExpr(e.Left, indent+"  ")
@@ -284,8 +1150,15 @@ func PrintExpr(expr Expr, indent string) {
 }
 
 func (p *Parser) nextToken() {
+	p.lastEnd = p.curToken.End
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	// Surface lexical errors, such as unterminated comments, as parse errors.
+	for _, err := range p.l.Errors()[p.lexErrors:] {
+		p.addError(&ParserError{Msg: err.Msg, Detail: err.Detail, Hint: err.Hint, Pos: err.Pos, End: err.End})
+	}
+	p.lexErrors = len(p.l.Errors())
 }
 
 func (p *Parser) curTokenIs(t token.TokenType) bool {
@@ -297,35 +1170,108 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 		p.nextToken()
 		return true
 	}
-	p.errors = append(p.errors, &ParserError{
+	p.addError(&ParserError{
 		Msg:    fmt.Sprintf("Expected next token to be %s", t),
 		Detail: fmt.Sprintf("Got %q (%s) instead.", p.peekToken.Literal, p.peekToken.Type),
 		Hint:   "Check the syntax of your program.",
+		Pos:    p.peekToken.Pos,
+		End:    p.peekToken.End,
 	})
 	return false
 }
 
+// addError records a parse error. Errors without an explicit position are
+// reported at the current token.
+func (p *Parser) addError(err *ParserError) {
+	if !err.Pos.IsValid() {
+		err.Pos, err.End = p.curToken.Pos, p.curToken.End
+	}
+	if !err.End.IsValid() {
+		err.End = err.Pos
+	}
+	// One error per position: later ones are usually fallout from the first.
+	if n := len(p.errors); n > 0 && p.errors[n-1].Pos == err.Pos {
+		return
+	}
+	p.errors = append(p.errors, err)
+}
+
+// spanFrom returns the span from start to the end of the last consumed token.
+func (p *Parser) spanFrom(start token.Position) Span {
+	return Span{StartPos: start, EndPos: p.lastEnd}
+}
+
+// parseRelational parses a relational expression. Pascal relational
+// operators do not associate, so 'a < b < c' is rejected.
+func (p *Parser) parseRelational() Expr {
+	start := p.curToken.Pos
+	left := p.parseAddition()
+
+	if isRelational(p.curToken.Type) {
+		op := p.curToken
+		p.nextToken()
+		right := p.parseAddition()
+		left = &BinaryExpr{Span: p.spanFrom(start), Left: left, Operator: op, Right: right}
+
+		if isRelational(p.curToken.Type) {
+			p.addError(&ParserError{
+				Msg:    fmt.Sprintf("Unexpected '%s' after comparison", p.curToken.Literal),
+				Detail: "Comparisons cannot be chained in Pascal.",
+				Hint:   "Combine comparisons with 'and', e.g. '(a < b) and (b < c)'.",
+			})
+			// Skip the rest of the chain so it isn't reported again.
+			for isRelational(p.curToken.Type) {
+				p.nextToken()
+				p.parseAddition()
+			}
+		}
+	}
+
+	return left
+}
+
+func isRelational(t token.TokenType) bool {
+	switch t {
+	case token.EQUAL, token.NEQ, token.LT, token.LE, token.GT, token.GE, token.IN:
+		return true
+	}
+	return false
+}
+
+// parseAddition parses a simple expression: terms joined by '+', '-' and
+// 'or'. The first term may have a sign, which applies to the whole term,
+// so '-x * 2' is '-(x * 2)'.
 func (p *Parser) parseAddition() Expr {
-	left := p.parseMultiplication()
+	start := p.curToken.Pos
+	var left Expr
+	if p.curTokenIs(token.PLUS) || p.curTokenIs(token.MINUS) {
+		op := p.curToken
+		p.nextToken()
+		operand := p.parseMultiplication()
+		left = &UnaryExpr{Span: p.spanFrom(start), Operator: op, Operand: operand}
+	} else {
+		left = p.parseMultiplication()
+	}
 
-	for p.curTokenIs(token.PLUS) || p.curTokenIs(token.MINUS) {
+	for p.curTokenIs(token.PLUS) || p.curTokenIs(token.MINUS) || p.curTokenIs(token.OR) {
 		op := p.curToken
 		p.nextToken()
 		right := p.parseMultiplication()
-		left = &BinaryExpr{Left: left, Operator: op, Right: right}
+		left = &BinaryExpr{Span: p.spanFrom(start), Left: left, Operator: op, Right: right}
 	}
 
 	return left
 }
 
 func (p *Parser) parseMultiplication() Expr {
+	start := p.curToken.Pos
 	left := p.parsePrimary()
 
-	for p.curTokenIs(token.STAR) || p.curTokenIs(token.SLASH) {
+	for p.curTokenIs(token.STAR) || p.curTokenIs(token.SLASH) || p.curTokenIs(token.DIV) || p.curTokenIs(token.MOD) || p.curTokenIs(token.AND) {
 		op := p.curToken
 		p.nextToken()
 		right := p.parsePrimary()
-		left = &BinaryExpr{Left: left, Operator: op, Right: right}
+		left = &BinaryExpr{Span: p.spanFrom(start), Left: left, Operator: op, Right: right}
 	}
 
 	return left
@@ -334,15 +1280,17 @@ func (p *Parser) parseMultiplication() Expr {
 func (p *Parser) parsePrimary() Expr {
 	switch p.curToken.Type {
 	case token.LPAREN:
+		lparen := p.curToken
 		p.nextToken() // Advance from '(' to first token inside
 
 		expr := p.ParseExpression()
 
 		if !p.curTokenIs(token.RPAREN) {
-			p.errors = append(p.errors, &ParserError{
+			p.addError(&ParserError{
 				Msg:    "Expected closing parenthesis",
 				Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
 				Hint:   "Ensure all opening parentheses have matching closing parentheses.",
+				Labels: []diagnostics.Label{{Pos: lparen.Pos, End: lparen.End, Msg: "unclosed '(' opened here"}},
 			})
 			return nil
 		}
@@ -351,81 +1299,85 @@ func (p *Parser) parsePrimary() Expr {
 		return expr
 
 	case token.INT:
-		val, _ := strconv.Atoi(p.curToken.Literal)
-		lit := &IntegerLiteral{Value: val}
+		val, err := strconv.Atoi(p.curToken.Literal)
+		if err != nil {
+			p.addError(&ParserError{
+				Msg:    "Integer literal exceeds maxint",
+				Detail: fmt.Sprintf("%s is larger than maxint, the largest integer, which is %d.", p.curToken.Literal, math.MaxInt),
+				Hint:   "Use a smaller number, or a real such as 1e20 for values this large.",
+			})
+		}
+		lit := &IntegerLiteral{Span: Span{p.curToken.Pos, p.curToken.End}, Value: val}
 		p.nextToken()
 		return lit
 
-	case token.IDENT:
-		ident := &Identifier{Value: p.curToken.Literal}
+	case token.REAL:
+		val, err := strconv.ParseFloat(p.curToken.Literal, 64)
+		if err != nil {
+			p.addError(&ParserError{
+				Msg:    "Real number out of range",
+				Detail: fmt.Sprintf("%s is too large to be represented as a real.", p.curToken.Literal),
+				Hint:   "Use a smaller exponent.",
+			})
+		}
+		lit := &RealLiteral{Span: Span{p.curToken.Pos, p.curToken.End}, Value: val}
 		p.nextToken()
-		return ident
-
-	default:
-		p.errors = append(p.errors, &ParserError{
-			Msg:    "Unexpected token in primary expression",
-			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
-			Hint:   "Check the syntax of your expression.",
-		})
-		return nil
-	}
-}
-
-func (p *Parser) parseVarDecl() Stmt {
-	// Advance to the next token after 'var'
-	p.nextToken()
-
-	if p.curToken.Type != token.IDENT {
-		p.errors = append(p.errors, &ParserError{
-			Msg:    "Expected variable name after 'var'",
-			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
-			Hint:   "Variable declarations must start with a valid identifier.",
-		})
-		return nil
-	}
-
-	name := p.curToken.Literal
+		return lit
 
-	// Advance to the next token after the variable name
-	p.nextToken()
+	case token.STRING:
+		lit := &StringLiteral{Span: Span{p.curToken.Pos, p.curToken.End}, Value: p.curToken.Literal}
+		p.nextToken()
+		return lit
 
-	if p.curToken.Type != token.COLON {
-		p.errors = append(p.errors, &ParserError{
-			Msg:    "Expected ':' after variable name",
-			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
-			Hint:   "Variable declarations must specify a type after the colon.",
-		})
-		return nil
-	}
+	case token.NIL:
+		lit := &NilLiteral{Span: Span{p.curToken.Pos, p.curToken.End}}
+		p.nextToken()
+		return lit
 
-	// Advance to the next token after ':'
-	p.nextToken()
+	case token.LBRACKET:
+		return p.parseSetConstructor()
 
-	if p.curToken.Type != token.INTEGER {
-		p.errors = append(p.errors, &ParserError{
-			Msg:    "Expected 'integer' type for variable",
-			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
-			Hint:   "Currently, only 'integer' type is supported for variables.",
+	case token.NOT:
+		start := p.curToken.Pos
+		op := p.curToken
+		p.nextToken()
+		operand := p.parsePrimary()
+		return &UnaryExpr{Span: p.spanFrom(start), Operator: op, Operand: operand}
+
+	case token.PLUS, token.MINUS:
+		// A sign may only start a simple expression, as in 'a * (-b)'.
+		p.addError(&ParserError{
+			Msg:    fmt.Sprintf("Unexpected '%s' after an operator", p.curToken.Literal),
+			Detail: "A sign can only come at the start of an expression, not after another operator.",
+			Hint:   fmt.Sprintf("Put the signed operand in parentheses, e.g. 'a * (%sb)'.", p.curToken.Literal),
 		})
-		return nil
-	}
+		start := p.curToken.Pos
+		op := p.curToken
+		p.nextToken()
+		operand := p.parsePrimary()
+		return &UnaryExpr{Span: p.spanFrom(start), Operator: op, Operand: operand}
 
-	varType := p.curToken.Literal
+	case token.IDENT:
+		ident := &Identifier{Span: Span{p.curToken.Pos, p.curToken.End}, Value: p.curToken.Literal}
+		p.nextToken()
 
-	// Advance to the next token after the type
-	p.nextToken()
+		if p.curTokenIs(token.LPAREN) {
+			args, ok := p.parseArgs()
+			if !ok {
+				return nil
+			}
+			call := &CallExpr{Span: p.spanFrom(ident.Pos()), Name: ident.Value, Args: args}
+			p.checkCall(call.Name, len(args), call)
+			return call
+		}
+		return p.parseSelectors(ident)
 
-	if p.curToken.Type != token.SEMICOLON {
-		p.errors = append(p.errors, &ParserError{
-			Msg:    "Expected ';' after variable declaration",
+	default:
+		p.addError(&ParserError{
+			Msg:    "Unexpected token in primary expression",
 			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
-			Hint:   "Variable declarations must end with a semicolon.",
+			Hint:   "Check the syntax of your expression.",
 		})
 		return nil
 	}
-
-	// Advance to the next token after the semicolon
-	p.nextToken()
-
-	return &VarDecl{Name: name, Type: varType}
 }
@@ -0,0 +1,207 @@
+package parser_test
+
+import (
+	"slices"
+	"testing"
+
+	"pastel/dialect"
+	"pastel/lexer"
+	"pastel/parser"
+)
+
+// parse parses src in the dialect d and returns the messages of the errors
+// found, in order.
+func parse(d dialect.Dialect, src string) []string {
+	p := parser.New(lexer.NewFile("t.pas", src, d))
+	p.ParseProgram()
+	var msgs []string
+	for _, err := range p.Errors() {
+		msgs = append(msgs, err.Msg)
+	}
+	return msgs
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect dialect.Dialect
+		src     string
+		want    []string
+	}{
+		{
+			name: "valid program",
+			src: `program ok;
+const n = 3;
+type day = (mon, tue, wed);
+var d: day; i: integer;
+begin
+  for i := 1 to n do
+    case i of
+      1: d := mon;
+      2, 3: d := wed
+    end;
+  writeln(ord(d))
+end.`,
+		},
+		{
+			name: "duplicate case label",
+			src: `program dup;
+var i: integer;
+begin
+  case i of
+    1, 2: writeln('a');
+    3, 1: writeln('b')
+  end
+end.`,
+			want: []string{"Duplicate case label"},
+		},
+		{
+			name: "case label inside an earlier range",
+			src: `program dup;
+var i: integer;
+begin
+  case i of
+    1..5: writeln('a');
+    4: writeln('b')
+  end
+end.`,
+			want: []string{"Duplicate case label"},
+		},
+		{
+			name:    "otherwise arm in iso",
+			dialect: dialect.ISO,
+			src: `program arm;
+var i: integer;
+begin
+  case i of
+    1: writeln('a')
+    else writeln('b')
+  end
+end.`,
+			want: []string{"'else' arm in case statement is not allowed in the iso dialect"},
+		},
+		{
+			name:    "otherwise as a name in iso",
+			dialect: dialect.ISO,
+			src: `program name;
+var otherwise: integer;
+begin
+  otherwise := 1
+end.`,
+		},
+		{
+			name: "integer literal above maxint",
+			src: `program big;
+var i: integer;
+begin
+  i := 99999999999999999999
+end.`,
+			want: []string{"Integer literal exceeds maxint"},
+		},
+		{
+			name: "constant expression overflow",
+			src: `program big;
+const big = maxint + 1;
+begin
+end.`,
+			want: []string{"Integer overflow in constant expression"},
+		},
+		{
+			name: "constant division by zero",
+			src: `program zero;
+const z = 1 div 0;
+begin
+end.`,
+			want: []string{"Division by zero in constant expression"},
+		},
+		{
+			name: "constant mod by a negative number",
+			src: `program neg;
+const m = 7 mod (0 - 2);
+begin
+end.`,
+			want: []string{"Negative modulus in constant expression"},
+		},
+		{
+			name:    "constant mod by a negative number in turbo",
+			dialect: dialect.Turbo,
+			src: `program neg;
+const m = 7 mod (0 - 2);
+begin
+end.`,
+		},
+		{
+			name: "undeclared procedure",
+			src: `program call;
+begin
+  nothing(1)
+end.`,
+			want: []string{"Undeclared procedure or function 'nothing'"},
+		},
+		{
+			name: "wrong number of arguments",
+			src: `program call;
+procedure p(a, b: integer);
+begin
+end;
+begin
+  p(1);
+  writeln(ord(1, 2))
+end.`,
+			want: []string{
+				"Wrong number of arguments in call to procedure 'p'",
+				"Wrong number of arguments in call to function 'ord'",
+			},
+		},
+		{
+			name: "eof without a file",
+			src: `program call;
+begin
+  writeln(eof)
+end.`,
+		},
+		{
+			name: "required names redeclared",
+			src: `program names;
+const true = 0;
+type boolean = integer;
+var read, write: boolean;
+begin
+  read := true;
+  write := read
+end.`,
+		},
+		{
+			name:    "string type in iso",
+			dialect: dialect.ISO,
+			src: `program s;
+var s: string;
+begin
+end.`,
+			want: []string{"The string type is not available in the iso dialect"},
+		},
+		{
+			name: "recovery after a bad type",
+			src: `program recover;
+type a = array[1..0] of integer;
+     b = nothing;
+     c = record x: integer; x: char end;
+var v: a;
+begin
+end.`,
+			want: []string{"Empty subrange", "Unknown type 'nothing'", "Duplicate field 'x'"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.dialect
+			if d.Name == "" {
+				d = dialect.Pastel
+			}
+			if got := parse(d, tt.src); !slices.Equal(got, tt.want) {
+				t.Errorf("errors %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,1221 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"pastel/diagnostics"
+	"pastel/token"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Type is a Pascal type, resolved by the parser from a type denoter. Type
+// names are resolved while parsing, so an alias shares the Type of the type
+// it names.
+type Type interface {
+	String() string // the type's name, or its denoter if it has none
+}
+
+// BasicType is one of the required types, such as integer.
+type BasicType struct {
+	Name string
+}
+
+func (t *BasicType) String() string { return t.Name }
+
+var (
+	Integer = &BasicType{Name: "integer"}
+	Boolean = &BasicType{Name: "boolean"}
+	Real    = &BasicType{Name: "real"}
+	Char    = &BasicType{Name: "char"}
+	String  = &BasicType{Name: "string"}
+)
+
+// MaxChar is the largest ordinal number of a char. Characters are bytes.
+const MaxChar = 255
+
+// EnumType is an enumerated type such as '(red, green, blue)'. The ordinal
+// number of each value is its index in Values.
+type EnumType struct {
+	Name   string // empty for an anonymous type
+	Values []string
+}
+
+func (t *EnumType) String() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return "(" + strings.Join(t.Values, ", ") + ")"
+}
+
+// SubrangeType is the range Low..High of the ordinal type Host, given as
+// ordinal numbers.
+type SubrangeType struct {
+	Name      string // empty for an anonymous type
+	Host      Type
+	Low, High int
+}
+
+func (t *SubrangeType) String() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return FormatOrdinal(t.Host, t.Low) + ".." + FormatOrdinal(t.Host, t.High)
+}
+
+// ArrayType is 'array[Index] of Elem'. An array with several index types,
+// 'array[a, b] of T', is an array[a] of array[b] of T. Packed is set for
+// 'packed array'; see IsStringType.
+type ArrayType struct {
+	Name   string // empty for an anonymous type
+	Index  Type
+	Elem   Type
+	Packed bool
+}
+
+func (t *ArrayType) String() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	s := "array[" + t.Index.String() + "] of " + t.Elem.String()
+	if t.Packed {
+		s = "packed " + s
+	}
+	return s
+}
+
+// IsStringType reports whether t is a string type of ISO Pascal, a 'packed
+// array[1..n] of char'. A string of exactly n characters can be assigned
+// to it, and its values can be written and compared like strings.
+func IsStringType(t Type) bool {
+	a, ok := t.(*ArrayType)
+	if !ok || !a.Packed || Host(a.Elem) != Char || Host(a.Index) != Integer {
+		return false
+	}
+	low, _ := Bounds(a.Index)
+	return low == 1
+}
+
+// RecordType is 'record fields end'. Fields holds every field, including
+// the tag fields and the fields of variants; Parts holds the variant parts.
+type RecordType struct {
+	Name   string // empty for an anonymous type
+	Fields []*Field
+	Parts  []*VariantPart
+}
+
+func (t *RecordType) String() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	var fields []string
+	for _, f := range t.Fields {
+		fields = append(fields, f.Name+": "+f.Type.String())
+	}
+	return "record " + strings.Join(fields, "; ") + " end"
+}
+
+// FieldIndex returns the index in Fields of the field called name, or -1
+// if the record has no such field.
+func (t *RecordType) FieldIndex(name string) int {
+	for i, f := range t.Fields {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Field is a field of a record type. Variant is the variant the field
+// belongs to, or nil if it is in the fixed part of the record. The tag
+// field of a variant part has Part set.
+type Field struct {
+	Span
+	Name    string
+	Type    Type
+	Variant *Variant
+	Part    *VariantPart
+}
+
+// VariantPart is the 'case' part at the end of a record, or of a variant.
+// Which variant is active is selected by the tag field Tag, or, if there is
+// none, by the last assignment to a field of a variant.
+type VariantPart struct {
+	Span
+	Index    int    // position in the record type's Parts
+	Tag      *Field // nil if the part has no tag field
+	TagType  Type
+	Variants []*Variant
+	Parent   *Variant // the variant the part is nested in, or nil
+}
+
+// Variant is one variant of a variant part. Labels holds the ordinal numbers
+// of the tag values that select it.
+type Variant struct {
+	Labels []int
+	Part   *VariantPart
+}
+
+// String describes the variant for messages, e.g. "the variant for
+// kind = circle", or "the variant for circle" if its part has no tag field.
+func (v *Variant) String() string {
+	var labels []string
+	for _, n := range v.Labels {
+		labels = append(labels, FormatOrdinal(v.Part.TagType, n))
+	}
+	if v.Part.Tag == nil {
+		return "the variant for " + strings.Join(labels, ", ")
+	}
+	return "the variant for " + v.Part.Tag.Name + " = " + strings.Join(labels, ", ")
+}
+
+// PointerType is '^Target', the type of pointers to variables of type
+// Target created by new.
+type PointerType struct {
+	Name   string // empty for an anonymous type
+	Target Type
+	domain string // the name Target was declared with
+}
+
+func (t *PointerType) String() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	// Print the domain's name rather than recursing into a type that may
+	// point back to t.
+	return "^" + t.domain
+}
+
+// SetType is 'set of Base', whose values are sets of values of the ordinal
+// type Base. The ordinal numbers of Base must lie in 0..MaxSetOrdinal.
+type SetType struct {
+	Name string // empty for an anonymous type
+	Base Type   // nil for the type of the empty set '[]'
+}
+
+func (t *SetType) String() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	if t.Base == nil {
+		return "set of nothing"
+	}
+	return "set of " + t.Base.String()
+}
+
+// FileType is 'file of Elem', a sequence of components of type Elem kept
+// in a file outside the program. The required type text is a file of lines
+// of characters; its Elem is nil.
+type FileType struct {
+	Name string // empty for an anonymous type
+	Elem Type
+}
+
+func (t *FileType) String() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return "file of " + t.Elem.String()
+}
+
+// Text is the required type text.
+var Text = &FileType{Name: "text"}
+
+// UniverseTypes holds the predeclared type names that are not keywords.
+var UniverseTypes = []*TypeDecl{
+	{Name: "boolean", Type: Boolean},
+	{Name: "real", Type: Real},
+	{Name: "char", Type: Char},
+	{Name: "string", Type: String},
+	{Name: "text", Type: Text},
+}
+
+// StandardFiles holds the required text file variables input and output,
+// which are bound to the standard input and output.
+var StandardFiles = []*VarDecl{
+	{Name: "input", Type: Text},
+	{Name: "output", Type: Text},
+}
+
+// MaxSetOrdinal is the largest ordinal number a set can hold, so that a set
+// fits in a small bitset.
+const MaxSetOrdinal = 255
+
+// pointerDomain is a pointer type whose domain type, named by name, is
+// resolved at the end of the section it is declared in. This lets a type
+// section declare a pointer to a record type before the record, e.g.
+//
+//	type link = ^node;
+//	     node = record next: link end;
+type pointerDomain struct {
+	typ  *PointerType
+	name token.Token
+}
+
+// maxArrayLength bounds the number of components along one index of an
+// array, so that an index type such as integer is rejected rather than
+// exhausting memory.
+const maxArrayLength = 1 << 24
+
+// Host returns the type t is a subrange of, or t itself. Values of a
+// subrange type are values of its host type.
+func Host(t Type) Type {
+	if s, ok := t.(*SubrangeType); ok {
+		return s.Host
+	}
+	return t
+}
+
+// IsOrdinal reports whether t is an ordinal type.
+func IsOrdinal(t Type) bool {
+	switch t := t.(type) {
+	case *BasicType:
+		return t == Integer || t == Boolean || t == Char
+	case *EnumType, *SubrangeType:
+		return true
+	default:
+		return false
+	}
+}
+
+// Bounds returns the smallest and largest ordinal numbers of the ordinal
+// type t. Those of integer are -maxint and maxint.
+func Bounds(t Type) (low, high int) {
+	switch t := t.(type) {
+	case *EnumType:
+		return 0, len(t.Values) - 1
+	case *SubrangeType:
+		return t.Low, t.High
+	default:
+		if t == Boolean {
+			return 0, 1
+		}
+		if t == Char {
+			return 0, MaxChar
+		}
+		return -math.MaxInt, math.MaxInt
+	}
+}
+
+// FormatOrdinal returns the value of the ordinal type t with ordinal number
+// n as it is written in source, for messages.
+func FormatOrdinal(t Type, n int) string {
+	switch t := Host(t).(type) {
+	case *EnumType:
+		if n >= 0 && n < len(t.Values) {
+			return t.Values[n]
+		}
+	default:
+		if t == Boolean {
+			return strconv.FormatBool(n != 0)
+		}
+		if t == Char {
+			return FormatChar(n)
+		}
+	}
+	return strconv.Itoa(n)
+}
+
+// FormatChar returns the character with ordinal number n as it is written
+// in source: quoted if it is printable, and otherwise as a call of chr.
+func FormatChar(n int) string {
+	switch {
+	case n == '\'':
+		return "''''"
+	case n >= ' ' && n <= '~':
+		return "'" + string(rune(n)) + "'"
+	default:
+		return fmt.Sprintf("chr(%d)", n)
+	}
+}
+
+// TypeDecl declares a named type in a 'type' section.
+type TypeDecl struct {
+	Span
+	Name string
+	Type Type
+}
+
+// EnumLiteral is a value of an enumerated type. The parser folds references
+// to enumeration constants into EnumLiterals.
+type EnumLiteral struct {
+	Span
+	Type *EnumType
+	Ord  int
+}
+
+// literalType returns the type of the folded constant lit.
+func literalType(lit Expr) Type {
+	switch l := lit.(type) {
+	case *EnumLiteral:
+		return l.Type
+	case *BooleanLiteral:
+		return Boolean
+	case *RealLiteral:
+		return Real
+	case *StringLiteral:
+		if l.IsChar() {
+			return Char
+		}
+		return String
+	default:
+		return Integer
+	}
+}
+
+// lookupType returns the type declaration name refers to, or nil if name
+// does not name a type in the current block. A type whose definition had
+// an error is declared with a nil Type, so that its uses fail quietly.
+func (p *Parser) lookupType(name string) *TypeDecl {
+	decl, _ := p.lookup(name).(*TypeDecl)
+	return decl
+}
+
+// named is a type that can be given a name by a type definition: every
+// type denoter other than the name of an existing type.
+type named interface {
+	Type
+	typeName() string
+	setTypeName(name string)
+}
+
+func (t *EnumType) typeName() string     { return t.Name }
+func (t *SubrangeType) typeName() string { return t.Name }
+func (t *ArrayType) typeName() string    { return t.Name }
+func (t *RecordType) typeName() string   { return t.Name }
+func (t *PointerType) typeName() string  { return t.Name }
+func (t *SetType) typeName() string      { return t.Name }
+func (t *FileType) typeName() string     { return t.Name }
+
+func (t *EnumType) setTypeName(name string)     { t.Name = name }
+func (t *SubrangeType) setTypeName(name string) { t.Name = name }
+func (t *ArrayType) setTypeName(name string)    { t.Name = name }
+func (t *RecordType) setTypeName(name string)   { t.Name = name }
+func (t *PointerType) setTypeName(name string)  { t.Name = name }
+func (t *SetType) setTypeName(name string)      { t.Name = name }
+func (t *FileType) setTypeName(name string)     { t.Name = name }
+
+// parseTypeSection parses 'type' followed by one or more definitions of the
+// form 'name = type ;'.
+func (p *Parser) parseTypeSection() []Stmt {
+	// Advance to the next token after 'type'
+	p.nextToken()
+
+	if !p.curTokenIs(token.IDENT) {
+		p.addError(&ParserError{
+			Msg:    "Expected type name after 'type'",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "Type definitions are written 'name = type;'.",
+		})
+		return nil
+	}
+
+	var decls []Stmt
+	for p.curTokenIs(token.IDENT) {
+		start := p.curToken.Pos
+		name := p.curToken.Literal
+
+		if !p.expectPeek(token.EQUAL) {
+			return decls
+		}
+
+		// Advance to the next token after '='
+		p.nextToken()
+
+		typ, ok := p.parseType()
+		if !ok {
+			// Carry on with the next definition, with this name declared
+			// so that its uses are not reported as well.
+			p.declare(name, &TypeDecl{Span: p.spanFrom(start), Name: name})
+			p.skipDeclaration()
+			if !p.curTokenIs(token.SEMICOLON) {
+				return decls
+			}
+			p.nextToken()
+			continue
+		}
+
+		if !p.curTokenIs(token.SEMICOLON) {
+			p.addError(&ParserError{
+				Msg:    "Expected ';' after type definition",
+				Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+				Hint:   "Type definitions must end with a semicolon.",
+			})
+			return decls
+		}
+
+		// Advance to the next token after the semicolon
+		p.nextToken()
+
+		// A new type takes the name it is defined with; an alias keeps the
+		// name of the type it renames.
+		if t, ok := typ.(named); ok && t.typeName() == "" {
+			t.setTypeName(name)
+		}
+
+		decl := &TypeDecl{Span: p.spanFrom(start), Name: name, Type: typ}
+		p.declare(name, decl)
+		decls = append(decls, decl)
+	}
+
+	return decls
+}
+
+// parseType parses a type denoter: the name of a type, an enumerated type
+// '(name {, name})', a subrange 'constant .. constant', or an array, record,
+// pointer, set or file type.
+func (p *Parser) parseType() (Type, bool) {
+	switch p.curToken.Type {
+	case token.PACKED:
+		return p.parsePackedType()
+
+	case token.ARRAY:
+		return p.parseArrayType(false)
+
+	case token.RECORD:
+		return p.parseRecordType()
+
+	case token.CARET:
+		return p.parsePointerType()
+
+	case token.SET:
+		return p.parseSetType()
+
+	case token.FILE:
+		return p.parseFileType()
+
+	case token.INTEGER:
+		return p.parseTypeName()
+
+	case token.IDENT:
+		if decl := p.lookupType(p.curToken.Literal); decl != nil {
+			p.nextToken()
+			return decl.Type, decl.Type != nil
+		}
+		if p.lookupConst(p.curToken.Literal) == nil {
+			p.unknownType()
+			return nil, false
+		}
+		return p.parseSubrangeType()
+
+	case token.LPAREN:
+		return p.parseEnumType()
+
+	default:
+		return p.parseSubrangeType()
+	}
+}
+
+// unknownType reports that the current token does not name a type.
+func (p *Parser) unknownType() {
+	name := p.curToken.Literal
+	if name == String.Name && !p.l.Dialect().StringType {
+		p.addError(&ParserError{
+			Msg:    fmt.Sprintf("The string type is not available in the %s dialect", p.l.Dialect().Name),
+			Detail: "'string' is a Turbo Pascal extension.",
+			Hint:   "Use a string type of ISO Pascal instead, e.g. 'packed array[1..20] of char'.",
+		})
+		return
+	}
+	p.addError(&ParserError{
+		Msg:    fmt.Sprintf("Unknown type '%s'", name),
+		Detail: fmt.Sprintf("'%s' is not the name of a type.", name),
+		Hint:   "Declare the type in a 'type' section before using it.",
+	})
+}
+
+// parseTypeName parses the name of a type, as required for parameters and
+// function results.
+func (p *Parser) parseTypeName() (Type, bool) {
+	var typ Type
+	switch p.curToken.Type {
+	case token.INTEGER:
+		typ = Integer
+	case token.IDENT:
+		if decl := p.lookupType(p.curToken.Literal); decl != nil {
+			if decl.Type == nil {
+				p.nextToken()
+				return nil, false
+			}
+			typ = decl.Type
+		}
+	}
+
+	if typ == nil && p.curTokenIs(token.IDENT) {
+		p.unknownType()
+		return nil, false
+	}
+	if typ == nil {
+		p.addError(&ParserError{
+			Msg:    "Expected a type",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "Use 'integer', 'boolean', or the name of a type declared in a 'type' section.",
+		})
+		return nil, false
+	}
+
+	// Advance to the next token after the type
+	p.nextToken()
+
+	return typ, true
+}
+
+// parseEnumType parses '(name {, name})'. Each name is declared as a
+// constant of the new type in the current block.
+func (p *Parser) parseEnumType() (Type, bool) {
+	lparen := p.curToken
+
+	// Advance to the next token after '('
+	p.nextToken()
+
+	names := p.parseIdentList()
+
+	if !p.curTokenIs(token.RPAREN) {
+		p.addError(&ParserError{
+			Msg:    "Expected ')' after enumerated values",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "The values of an enumerated type are separated by commas, e.g. '(red, green, blue)'.",
+			Labels: []diagnostics.Label{{Pos: lparen.Pos, End: lparen.End, Msg: "unclosed '(' opened here"}},
+		})
+		return nil, false
+	}
+	p.nextToken()
+
+	typ := &EnumType{}
+	for i, name := range names {
+		typ.Values = append(typ.Values, name.Value)
+
+		decl := &ConstDecl{Span: name.Span, Name: name.Value, Value: &EnumLiteral{Span: name.Span, Type: typ, Ord: i}}
+		p.declare(name.Value, decl)
+		p.enumConsts = append(p.enumConsts, decl)
+	}
+	return typ, true
+}
+
+// parseSubrangeType parses 'constant .. constant'. Both bounds must be
+// constants of the same ordinal type, and the range must not be empty.
+func (p *Parser) parseSubrangeType() (Type, bool) {
+	start := p.curToken.Pos
+
+	low, ok := p.foldConstant(p.ParseExpression())
+	if !ok {
+		return nil, false
+	}
+
+	if !p.curTokenIs(token.DOTDOT) {
+		p.addError(&ParserError{
+			Msg:    "Expected a type",
+			Detail: fmt.Sprintf("Got %q (%s) instead of '..'.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "A type is a type name, an enumeration such as '(red, green)', or a subrange such as '1..10'.",
+			Pos:    start,
+			End:    p.curToken.End,
+		})
+		return nil, false
+	}
+
+	// Advance to the next token after '..'
+	p.nextToken()
+
+	high, ok := p.foldConstant(p.ParseExpression())
+	if !ok {
+		return nil, false
+	}
+
+	host := literalType(low)
+	if literalType(high) != host {
+		p.addError(&ParserError{
+			Msg:    "Subrange bounds have different types",
+			Detail: fmt.Sprintf("The lower bound is %s, but the upper bound is %s.", host, literalType(high)),
+			Hint:   "Both bounds of a subrange must be values of the same ordinal type.",
+			Pos:    start,
+			End:    p.lastEnd,
+		})
+		return nil, false
+	}
+	if !IsOrdinal(host) {
+		p.addError(&ParserError{
+			Msg:    "Subrange bounds must be of an ordinal type",
+			Detail: fmt.Sprintf("The bounds are %s values.", host),
+			Hint:   "Subranges are ranges of integer, boolean, char or enumerated values, e.g. '1..10'.",
+			Pos:    start,
+			End:    p.lastEnd,
+		})
+		return nil, false
+	}
+
+	lo, hi := literalOrdinal(low), literalOrdinal(high)
+	if lo > hi {
+		p.addError(&ParserError{
+			Msg:    "Empty subrange",
+			Detail: fmt.Sprintf("The lower bound %s is greater than the upper bound %s.", FormatOrdinal(host, lo), FormatOrdinal(host, hi)),
+			Hint:   "Write the smaller bound first, e.g. '1..10'.",
+			Pos:    start,
+			End:    p.lastEnd,
+		})
+		return nil, false
+	}
+
+	return &SubrangeType{Host: host, Low: lo, High: hi}, true
+}
+
+// parseArrayType parses 'array' '[' index {, index} ']' 'of' type, where
+// each index is an ordinal type.
+func (p *Parser) parseArrayType(packed bool) (Type, bool) {
+	if p.peekToken.Type != token.LBRACKET {
+		p.addError(&ParserError{
+			Msg:    "Expected '[' after 'array'",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.peekToken.Literal, p.peekToken.Type),
+			Hint:   "An array type is written 'array[1..10] of integer'.",
+			Pos:    p.peekToken.Pos,
+			End:    p.peekToken.End,
+		})
+		return nil, false
+	}
+	p.nextToken()
+	lbracket := p.curToken
+
+	// Advance to the next token after '['
+	p.nextToken()
+
+	var indices []Type
+	for {
+		start := p.curToken.Pos
+		index, ok := p.parseType()
+		if !ok {
+			return nil, false
+		}
+		if !IsOrdinal(index) {
+			p.addError(&ParserError{
+				Msg:    "Array index type must be an ordinal type",
+				Detail: fmt.Sprintf("%s is not an ordinal type.", index),
+				Hint:   "Index arrays by a subrange or enumerated type, e.g. 'array[1..10] of integer'.",
+				Pos:    start,
+				End:    p.lastEnd,
+			})
+			return nil, false
+		}
+		if low, high := Bounds(index); high-low >= maxArrayLength || high-low < 0 {
+			p.addError(&ParserError{
+				Msg:    "Array index type is too large",
+				Detail: fmt.Sprintf("Indexing by %s would need more than %d components.", index, maxArrayLength),
+				Hint:   "Use a subrange as the index type, e.g. 'array[1..100] of integer'.",
+				Pos:    start,
+				End:    p.lastEnd,
+			})
+			return nil, false
+		}
+		indices = append(indices, index)
+
+		if !p.curTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.curTokenIs(token.RBRACKET) {
+		p.addError(&ParserError{
+			Msg:    "Expected ']' after array index types",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "Index types are separated by commas and the list is closed with ']'.",
+			Labels: []diagnostics.Label{{Pos: lbracket.Pos, End: lbracket.End, Msg: "unclosed '[' opened here"}},
+		})
+		return nil, false
+	}
+
+	// Advance to the next token after ']'
+	p.nextToken()
+
+	if !p.curTokenIs(token.OF) {
+		p.addError(&ParserError{
+			Msg:    "Expected 'of' after array index types",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "An array type is written 'array[1..10] of integer'.",
+		})
+		return nil, false
+	}
+
+	// Advance to the next token after 'of'
+	p.nextToken()
+
+	typ, ok := p.parseType()
+	if !ok {
+		return nil, false
+	}
+	for _, index := range slices.Backward(indices) {
+		typ = &ArrayType{Index: index, Elem: typ, Packed: packed}
+	}
+	return typ, true
+}
+
+// parsePackedType parses 'packed' followed by an array, record, set or file
+// type. Packing only asks for compact storage, which Pastel ignores, but a
+// packed array[1..n] of char is a string type.
+func (p *Parser) parsePackedType() (Type, bool) {
+	// Advance to the next token after 'packed'
+	p.nextToken()
+
+	switch p.curToken.Type {
+	case token.ARRAY:
+		return p.parseArrayType(true)
+	case token.RECORD, token.SET, token.FILE:
+		return p.parseType()
+	default:
+		p.addError(&ParserError{
+			Msg:    "Expected an array, record, set or file type after 'packed'",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "Only structured types can be packed, e.g. 'packed array[1..10] of char'.",
+		})
+		return nil, false
+	}
+}
+
+// parsePointerType parses '^' type-name. The domain type is resolved by
+// resolvePointers at the end of the section.
+func (p *Parser) parsePointerType() (Type, bool) {
+	// Advance to the next token after '^'
+	p.nextToken()
+
+	typ := &PointerType{domain: p.curToken.Literal}
+	switch p.curToken.Type {
+	case token.INTEGER:
+		typ.Target = Integer
+	case token.IDENT:
+		p.pointers = append(p.pointers, &pointerDomain{typ: typ, name: p.curToken})
+	default:
+		p.addError(&ParserError{
+			Msg:    "Expected a type name after '^'",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "A pointer type is written '^name', where name is a type, e.g. '^node'.",
+		})
+		return nil, false
+	}
+
+	// Advance to the next token after the type name
+	p.nextToken()
+
+	return typ, true
+}
+
+// resolvePointers resolves the domain types of the pointer types parsed in
+// the section that has just ended.
+func (p *Parser) resolvePointers() {
+	for _, ptr := range p.pointers {
+		decl := p.lookupType(ptr.name.Literal)
+		if decl == nil {
+			p.addError(&ParserError{
+				Msg:    fmt.Sprintf("Unknown type '%s'", ptr.name.Literal),
+				Detail: fmt.Sprintf("'%s' is not the name of a type.", ptr.name.Literal),
+				Hint:   "Declare the type the pointer points to in a 'type' section.",
+				Pos:    ptr.name.Pos,
+				End:    ptr.name.End,
+			})
+			continue
+		}
+		ptr.typ.Target = decl.Type
+	}
+	p.pointers = nil
+}
+
+// parseSetType parses 'set' 'of' type, where the type is an ordinal type
+// whose values have ordinal numbers in 0..MaxSetOrdinal.
+func (p *Parser) parseSetType() (Type, bool) {
+	if p.peekToken.Type != token.OF {
+		p.addError(&ParserError{
+			Msg:    "Expected 'of' after 'set'",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.peekToken.Literal, p.peekToken.Type),
+			Hint:   "A set type is written 'set of 1..10' or 'set of color'.",
+			Pos:    p.peekToken.Pos,
+			End:    p.peekToken.End,
+		})
+		return nil, false
+	}
+	p.nextToken()
+
+	// Advance to the next token after 'of'
+	p.nextToken()
+
+	start := p.curToken.Pos
+	base, ok := p.parseType()
+	if !ok {
+		return nil, false
+	}
+	if !IsOrdinal(base) {
+		p.addError(&ParserError{
+			Msg:    "Set base type must be an ordinal type",
+			Detail: fmt.Sprintf("%s is not an ordinal type.", base),
+			Hint:   "Make a set of a subrange or enumerated type, e.g. 'set of 1..10'.",
+			Pos:    start,
+			End:    p.lastEnd,
+		})
+		return nil, false
+	}
+	if low, high := Bounds(base); low < 0 || high > MaxSetOrdinal {
+		p.addError(&ParserError{
+			Msg:    "Set base type is too large",
+			Detail: fmt.Sprintf("%s has values outside 0..%d, the ordinal numbers a set can hold.", base, MaxSetOrdinal),
+			Hint:   fmt.Sprintf("Use a smaller subrange as the base type, e.g. 'set of 0..%d'.", MaxSetOrdinal),
+			Pos:    start,
+			End:    p.lastEnd,
+		})
+		return nil, false
+	}
+
+	return &SetType{Base: base}, true
+}
+
+// parseFileType parses 'file' 'of' type. The components of a file are
+// values kept outside the program, so they cannot be files or pointers.
+func (p *Parser) parseFileType() (Type, bool) {
+	if p.peekToken.Type != token.OF {
+		p.addError(&ParserError{
+			Msg:    "Expected 'of' after 'file'",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.peekToken.Literal, p.peekToken.Type),
+			Hint:   "A file type is written 'file of integer', or use 'text' for a file of lines.",
+			Pos:    p.peekToken.Pos,
+			End:    p.peekToken.End,
+		})
+		return nil, false
+	}
+	p.nextToken()
+
+	// Advance to the next token after 'of'
+	p.nextToken()
+
+	start := p.curToken.Pos
+	elem, ok := p.parseType()
+	if !ok {
+		return nil, false
+	}
+	if bad := findType(elem, isFileOrPointer); bad != nil {
+		p.addError(&ParserError{
+			Msg:    "Invalid file component type",
+			Detail: fmt.Sprintf("The components of a file cannot contain %s values, which only have a meaning while the program runs.", bad),
+			Hint:   "Store the data the pointers or files refer to instead.",
+			Pos:    start,
+			End:    p.lastEnd,
+		})
+	}
+
+	return &FileType{Elem: elem}, true
+}
+
+// findType returns the first type among t and the types of its components
+// for which match is true, or nil. Pointer domains are not searched.
+func findType(t Type, match func(Type) bool) Type {
+	if match(t) {
+		return t
+	}
+	switch t := t.(type) {
+	case *ArrayType:
+		return findType(t.Elem, match)
+	case *RecordType:
+		for _, f := range t.Fields {
+			if found := findType(f.Type, match); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+func isFileOrPointer(t Type) bool {
+	switch t.(type) {
+	case *FileType, *PointerType:
+		return true
+	}
+	return false
+}
+
+// IsFile reports whether t is a file type, or has a component of one.
+func IsFile(t Type) bool {
+	return findType(t, func(t Type) bool { _, ok := t.(*FileType); return ok }) != nil
+}
+
+// parseRecordType parses 'record' field-list 'end'.
+func (p *Parser) parseRecordType() (Type, bool) {
+	record := p.curToken
+
+	// Advance to the next token after 'record'
+	p.nextToken()
+
+	typ := &RecordType{}
+	ok := p.parseFieldList(typ, nil, map[string]*Field{})
+	if !ok && !p.curTokenIs(token.END) {
+		return nil, false
+	}
+
+	if !p.curTokenIs(token.END) {
+		p.addError(&ParserError{
+			Msg:    "Expected 'end' after record fields",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "Fields are separated by semicolons and the record is closed with 'end'.",
+			Labels: []diagnostics.Label{{Pos: record.Pos, End: record.End, Msg: "record opened here"}},
+		})
+		return nil, false
+	}
+
+	// Advance to the next token after 'end'
+	p.nextToken()
+
+	if !ok {
+		return nil, false
+	}
+	return typ, true
+}
+
+// parseFieldList parses the fields of a record, or of the variant variant
+// of one: [field {';' field}] [';' variant-part] [';'], where each field is
+// name {, name} ':' type. The fields are added to typ; seen holds the
+// fields declared so far, as every field of a record needs its own name.
+// After an error in the type of a field, parsing carries on with the next
+// field, but the result is false.
+func (p *Parser) parseFieldList(typ *RecordType, variant *Variant, seen map[string]*Field) bool {
+	ok := true
+	for p.curTokenIs(token.IDENT) {
+		start := p.curToken.Pos
+		names := p.parseIdentList()
+
+		if !p.curTokenIs(token.COLON) {
+			p.addError(&ParserError{
+				Msg:    "Expected ':' after field name",
+				Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+				Hint:   "Fields are declared like variables, e.g. 'x, y: integer'.",
+			})
+			return false
+		}
+		p.nextToken()
+
+		fieldType, typeOK := p.parseType()
+		if typeOK {
+			for _, name := range names {
+				p.addField(typ, &Field{Span: Span{start, p.lastEnd}, Name: name.Value, Type: fieldType, Variant: variant}, name.Span, seen)
+			}
+		} else {
+			ok = false
+			p.skipDeclaration()
+		}
+
+		if !p.curTokenIs(token.SEMICOLON) {
+			return ok
+		}
+		p.nextToken()
+	}
+
+	if p.curTokenIs(token.CASE) {
+		return p.parseVariantPart(typ, variant, seen) && ok
+	}
+	return ok
+}
+
+// addField adds field to typ, reporting an error at name if a field of the
+// same name was already declared.
+func (p *Parser) addField(typ *RecordType, field *Field, name Span, seen map[string]*Field) {
+	if prev := seen[field.Name]; prev != nil {
+		p.addError(&ParserError{
+			Msg:    fmt.Sprintf("Duplicate field '%s'", field.Name),
+			Detail: "Each field of a record must have a different name.",
+			Hint:   "Rename one of the fields.",
+			Pos:    name.Pos(),
+			End:    name.End(),
+			Labels: []diagnostics.Label{{Pos: prev.Pos(), End: prev.End(), Msg: "first declared here"}},
+		})
+		return
+	}
+	seen[field.Name] = field
+	typ.Fields = append(typ.Fields, field)
+}
+
+// parseVariantPart parses
+//
+//	'case' [tag ':'] type 'of' variant {';' variant} [';']
+//
+// where each variant is constant {',' constant} ':' '(' field-list ')'.
+// parent is the variant the part is nested in, or nil.
+func (p *Parser) parseVariantPart(typ *RecordType, parent *Variant, seen map[string]*Field) bool {
+	part := &VariantPart{Span: Span{StartPos: p.curToken.Pos}, Parent: parent}
+
+	// Advance to the next token after 'case'
+	p.nextToken()
+
+	if p.curTokenIs(token.IDENT) && p.peekToken.Type == token.COLON {
+		part.Tag = &Field{Span: Span{p.curToken.Pos, p.curToken.End}, Name: p.curToken.Literal, Variant: parent, Part: part}
+		p.nextToken()
+		p.nextToken()
+	}
+
+	typeStart := p.curToken
+	tagType, ok := p.parseTypeName()
+	if !ok {
+		return false
+	}
+	if !IsOrdinal(tagType) {
+		p.addError(&ParserError{
+			Msg:    "Variant selector must be of an ordinal type",
+			Detail: fmt.Sprintf("%s is not an ordinal type.", tagType),
+			Hint:   "Select variants by an enumerated type, boolean or subrange.",
+			Pos:    typeStart.Pos,
+			End:    typeStart.End,
+		})
+		return false
+	}
+	part.TagType = tagType
+	if part.Tag != nil {
+		part.Tag.Type = tagType
+		part.Tag.Span.EndPos = p.lastEnd
+		p.addField(typ, part.Tag, part.Tag.Span, seen)
+	}
+
+	if !p.curTokenIs(token.OF) {
+		p.addError(&ParserError{
+			Msg:    "Expected 'of' in variant part",
+			Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+			Hint:   "A variant part is written 'case tag: type of ...'.",
+		})
+		return false
+	}
+	p.nextToken()
+
+	part.Index = len(typ.Parts)
+	typ.Parts = append(typ.Parts, part)
+
+	labels := map[int]Expr{}
+	failed := false
+	for !p.curTokenIs(token.END) && !p.curTokenIs(token.RPAREN) {
+		variant := &Variant{Part: part}
+
+		for {
+			expr := p.ParseExpression()
+			lit, ok := p.foldConstant(expr)
+			if !ok {
+				return false
+			}
+			n := literalOrdinal(lit)
+			if Host(literalType(lit)) != Host(tagType) {
+				p.addError(&ParserError{
+					Msg:    "Type mismatch in variant label",
+					Detail: fmt.Sprintf("The label is %s, but the variant selector is %s.", literalType(lit), tagType),
+					Hint:   "Variant labels must be values of the selector's type.",
+					Pos:    expr.Pos(),
+					End:    expr.End(),
+				})
+			} else if prev := labels[n]; prev != nil {
+				p.addError(&ParserError{
+					Msg:    fmt.Sprintf("Duplicate variant label %s", FormatOrdinal(tagType, n)),
+					Detail: "Each value of the selector can select only one variant.",
+					Hint:   "Remove the label from one of the variants.",
+					Pos:    expr.Pos(),
+					End:    expr.End(),
+					Labels: []diagnostics.Label{{Pos: prev.Pos(), End: prev.End(), Msg: "first used here"}},
+				})
+			} else {
+				labels[n] = expr
+				variant.Labels = append(variant.Labels, n)
+			}
+
+			if !p.curTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+
+		if !p.curTokenIs(token.COLON) {
+			p.addError(&ParserError{
+				Msg:    "Expected ':' after variant labels",
+				Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+				Hint:   "Each variant is written 'label: (fields)'.",
+			})
+			return false
+		}
+		p.nextToken()
+
+		if !p.curTokenIs(token.LPAREN) {
+			p.addError(&ParserError{
+				Msg:    "Expected '(' before variant fields",
+				Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+				Hint:   "The fields of a variant are written in parentheses, e.g. 'circle: (radius: integer)'.",
+			})
+			return false
+		}
+		lparen := p.curToken
+		p.nextToken()
+
+		if !p.parseFieldList(typ, variant, seen) {
+			if !p.curTokenIs(token.RPAREN) {
+				return false
+			}
+			failed = true
+		}
+
+		if !p.curTokenIs(token.RPAREN) {
+			p.addError(&ParserError{
+				Msg:    "Expected ')' after variant fields",
+				Detail: fmt.Sprintf("Got %q (%s) instead.", p.curToken.Literal, p.curToken.Type),
+				Hint:   "Fields are separated by semicolons and the variant is closed with ')'.",
+				Labels: []diagnostics.Label{{Pos: lparen.Pos, End: lparen.End, Msg: "variant opened here"}},
+			})
+			return false
+		}
+		p.nextToken()
+
+		part.Variants = append(part.Variants, variant)
+
+		if !p.curTokenIs(token.SEMICOLON) {
+			break
+		}
+		p.nextToken()
+	}
+
+	part.EndPos = p.lastEnd
+	return !failed
+}
+
+// staticType returns the declared type of the variable access expr, or nil
+// if it cannot be determined while parsing.
+func (p *Parser) staticType(expr Expr) Type {
+	switch e := expr.(type) {
+	case *Identifier:
+		switch decl := p.lookup(e.Value).(type) {
+		case *VarDecl:
+			return decl.Type
+		case *Param:
+			return decl.Type
+		case *Field:
+			return decl.Type
+		}
+	case *IndexExpr:
+		if a, ok := p.staticType(e.Array).(*ArrayType); ok {
+			return a.Elem
+		}
+	case *FieldExpr:
+		if r, ok := p.staticType(e.Record).(*RecordType); ok {
+			if i := r.FieldIndex(e.Field); i >= 0 {
+				return r.Fields[i].Type
+			}
+		}
+	case *DerefExpr:
+		if ptr, ok := p.staticType(e.Pointer).(*PointerType); ok {
+			return ptr.Target
+		}
+	}
+	return nil
+}
+
+// literalOrdinal returns the ordinal number of the folded constant lit.
+func literalOrdinal(lit Expr) int {
+	switch l := lit.(type) {
+	case *IntegerLiteral:
+		return l.Value
+	case *BooleanLiteral:
+		return boolOrd(l.Value)
+	case *EnumLiteral:
+		return l.Ord
+	case *StringLiteral:
+		if l.IsChar() {
+			return int(l.Value[0])
+		}
+		return 0
+	default:
+		return 0
+	}
+}
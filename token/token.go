@@ -1,12 +1,45 @@
 package token
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 type TokenType string
 
+// Position describes a location in a source file.
+type Position struct {
+	Filename string
+	Offset   int // byte offset, starting at 0
+	Line     int // line number, starting at 1
+	Column   int // column number in bytes, starting at 1
+}
+
+// IsValid reports whether the position has been set.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position as "file:line:col", or "line:col" when the
+// source has no file name.
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // position of the first character of the token
+	End     Position // position immediately after the token
 }
 
 const (
@@ -15,8 +48,10 @@ const (
 	EOF     = "EOF"
 
 	// Identifiers + literals
-	IDENT = "IDENT" // e.g., variable names
-	INT   = "INT"   // e.g., 123
+	IDENT  = "IDENT"  // e.g., variable names
+	INT    = "INT"    // e.g., 123
+	REAL   = "REAL"   // e.g., 3.14 or 1e-5
+	STRING = "STRING" // e.g., 'data.txt' or 'a'#10; the literal holds the characters it denotes
 
 	// Operators
 	ASSIGN = "ASSIGN" // :=
@@ -38,7 +73,11 @@ const (
 	COLON     = "COLON"     // :
 	LPAREN    = "LPAREN"    // (
 	RPAREN    = "RPAREN"    // )
+	LBRACKET  = "LBRACKET"  // [
+	RBRACKET  = "RBRACKET"  // ]
 	DOT       = "DOT"       // .
+	DOTDOT    = "DOTDOT"    // ..
+	CARET     = "CARET"     // ^
 
 	// Keywords
 	AND       = "AND"
@@ -64,6 +103,7 @@ const (
 	NOT       = "NOT"
 	OF        = "OF"
 	OR        = "OR"
+	OTHERWISE = "OTHERWISE"
 	PACKED    = "PACKED"
 	PROCEDURE = "PROCEDURE"
 	PROGRAM   = "PROGRAM"
@@ -107,6 +147,7 @@ var keywords = map[string]TokenType{
 	"not":       NOT,
 	"of":        OF,
 	"or":        OR,
+	"otherwise": OTHERWISE,
 	"packed":    PACKED,
 	"procedure": PROCEDURE,
 	"program":   PROGRAM,